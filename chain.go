@@ -0,0 +1,81 @@
+package goliburing
+
+// Chain Builds a sequence of linked SQEs that the kernel runs one after the other.
+// Each SQE of the chain only starts once the previous one completed. If one fails, or
+// a read or write completes short, the rest of the chain completes with ECANCELED,
+// see `CQE.Canceled`, unless the chain is hard linked.
+type Chain struct {
+	ring  *Ring
+	preps []func(sqe *SQE) error
+	sqes  []*SQE
+	hard  bool
+}
+
+// NewChain Create a new, empty Chain on ring.
+func NewChain(ring *Ring) *Chain {
+	return &Chain{
+		ring: ring,
+	}
+}
+
+// SetHardLink Keeps the chain going when an SQE fails, so every SQE runs in order whatever the results.
+func (c *Chain) SetHardLink() *Chain {
+	c.hard = true
+	return c
+}
+
+// Add Adds the next link of the chain, prepared with prep by `Submit`.
+// prep must not set the link flags itself, they are set by `Submit`, nor take other SQEs
+// from the ring, such as with `SQE.LinkTimeout`, since they would end up outside the chain.
+func (c *Chain) Add(prep func(sqe *SQE) error) *Chain {
+	c.preps = append(c.preps, prep)
+	return c
+}
+
+// Len Gets the number of SQEs in the chain.
+func (c *Chain) Len() int {
+	return len(c.preps)
+}
+
+// UserData Gets the user data of the SQEs of the chain in order, to match their completions.
+// It is only set once `Submit` took the SQEs from the ring.
+func (c *Chain) UserData() []uint64 {
+	userData := make([]uint64, len(c.sqes))
+	for i, sqe := range c.sqes {
+		userData[i] = sqe.UserData()
+	}
+
+	return userData
+}
+
+// Submit Takes the SQEs of the chain from the ring next to each other, prepares, links and submits them.
+// They are only made ready to go out once all of them are prepared and linked, so a submission from another
+// goroutine never sends part of the chain. Unlike `Ring.Submit`, the SQEs of `Ring.GetEmptySQE` are left
+// for the next `Ring.Submit`.
+// If a prep fails, the SQEs are discarded, see `SQE.Discard`, and its error is returned.
+func (c *Chain) Submit() (int, error) {
+	sqes, err := c.ring.getEmptySQEs(len(c.preps), false)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, prep := range c.preps {
+		if err := prep(sqes[i]); err != nil {
+			for _, sqe := range sqes {
+				sqe.Discard()
+			}
+			return 0, err
+		}
+	}
+	c.sqes = sqes
+
+	for i := 0; i < len(c.sqes)-1; i++ {
+		if c.hard {
+			c.sqes[i].SetHardLink()
+		} else {
+			c.sqes[i].SetLink()
+		}
+	}
+
+	return c.ring.submitSQEs(c.sqes...)
+}
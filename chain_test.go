@@ -0,0 +1,237 @@
+package goliburing
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+)
+
+// waitChain Reaps the completions of a submitted chain and gets their errors in chain order.
+func waitChain(t *testing.T, ring *Ring, chain *Chain) []error {
+	index := make(map[uint64]int)
+	for i, userData := range chain.UserData() {
+		index[userData] = i
+	}
+
+	errs := make([]error, chain.Len())
+	for i := 0; i < chain.Len(); i++ {
+		cqe, err := ring.WaitCQE()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, errs[index[cqe.UserData()]] = cqe.Result()
+		cqe.Seen()
+	}
+
+	return errs
+}
+
+func TestChain(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	f, err := os.OpenFile("/tmp/chain-test", os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	// The read only starts once the write completed, so it sees the data.
+	fd := int(f.Fd())
+	have := make([]byte, 5)
+	chain := NewChain(ring).
+		Add(func(sqe *SQE) error {
+			return sqe.PrepWriteV(fd, [][]byte{[]byte("hello")}, 0)
+		}).
+		Add(func(sqe *SQE) error {
+			sqe.PrepRead(fd, have, 0)
+			return nil
+		})
+	if _, err := chain.Submit(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, err := range waitChain(t, ring, chain) {
+		if err != nil {
+			t.Fatalf("link %d: %v", i, err)
+		}
+	}
+	if want := "hello"; want != string(have) {
+		t.Fatalf("Read: want %q, have %q", want, have)
+	}
+}
+
+func TestChainCanceled(t *testing.T) {
+	for _, hard := range []bool{false, true} {
+		ring, err := NewRing(8, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		chain := NewChain(ring)
+		if hard {
+			chain.SetHardLink()
+		}
+		for i := 0; i < 3; i++ {
+			chain.Add(func(sqe *SQE) error {
+				sqe.PrepRead(-1, make([]byte, 16), 0)
+				return nil
+			})
+		}
+		if _, err := chain.Submit(); err != nil {
+			t.Fatal(err)
+		}
+
+		errs := waitChain(t, ring, chain)
+		if errs[0] != syscall.EBADF {
+			t.Fatalf("hard link %v, link 0: want %v, have %v", hard, syscall.EBADF, errs[0])
+		}
+		for i, err := range errs[1:] {
+			// A failed link cancels the rest of the chain, unless it is hard linked.
+			want := syscall.ECANCELED
+			if hard {
+				want = syscall.EBADF
+			}
+			if err != want {
+				t.Fatalf("hard link %v, link %d: want %v, have %v", hard, i+1, want, err)
+			}
+		}
+		ring.Destroy()
+	}
+}
+
+func TestChainFull(t *testing.T) {
+	ring, err := NewRing(4, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	// The chain does not fit, so none of its SQEs are taken.
+	chain := NewChain(ring)
+	for i := 0; i < 5; i++ {
+		chain.Add(func(sqe *SQE) error {
+			sqe.prepNop()
+			return nil
+		})
+	}
+	_, err = chain.Submit()
+	if e, ok := err.(*ErrGetSQE); !ok || e.Code != ErrSQEFull {
+		t.Fatalf("Submit: want ErrSQEFull, have %v", err)
+	}
+	if want, have := 0, ring.InFlight(); want != have {
+		t.Fatalf("InFlight: want %d, have %d", want, have)
+	}
+}
+
+func TestChainPrepFailed(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	want := syscall.EINVAL
+	chain := NewChain(ring).
+		Add(func(sqe *SQE) error {
+			sqe.prepNop()
+			return nil
+		}).
+		Add(func(sqe *SQE) error {
+			return want
+		})
+	if _, err := chain.Submit(); err != want {
+		t.Fatalf("Submit: want %v, have %v", want, err)
+	}
+
+	// The discarded SQEs never go out, so only the next chain completes.
+	chain = NewChain(ring).
+		Add(func(sqe *SQE) error {
+			sqe.prepNop()
+			return nil
+		})
+	if _, err := chain.Submit(); err != nil {
+		t.Fatal(err)
+	}
+	for i, err := range waitChain(t, ring, chain) {
+		if err != nil {
+			t.Fatalf("link %d: %v", i, err)
+		}
+	}
+}
+
+func TestChainConcurrent(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	f, err := os.OpenFile("/tmp/chain-concurrent-test", os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	// Each chain writes its own block and reads it back, which only works if the links go out whole.
+	const goroutines, chains, size = 2, 32, 16
+	fd := int(f.Fd())
+	bufs := make([][]byte, goroutines*chains)
+	inFlight := make(chan struct{}, 2)
+	errs := make(chan error, goroutines)
+	for g := 0; g < goroutines; g++ {
+		go func(g int) {
+			for i := 0; i < chains; i++ {
+				block := g*chains + i
+				data := bytes.Repeat([]byte{byte(block)}, size)
+				bufs[block] = make([]byte, size)
+				inFlight <- struct{}{}
+				chain := NewChain(ring).
+					Add(func(sqe *SQE) error {
+						sqe.SetData(fmt.Sprintf("write %d", block))
+						return sqe.PrepWriteV(fd, [][]byte{data}, uint64(block*size))
+					}).
+					Add(func(sqe *SQE) error {
+						sqe.SetData(block)
+						sqe.PrepRead(fd, bufs[block], uint64(block*size))
+						return nil
+					})
+				if _, err := chain.Submit(); err != nil {
+					errs <- err
+					return
+				}
+			}
+			errs <- nil
+		}(g)
+	}
+
+	for reads := 0; reads < goroutines*chains; {
+		cqe, err := ring.WaitCQE()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := cqe.Result(); err != nil {
+			t.Fatalf("%v: %v", cqe.Data(), err)
+		}
+		if block, ok := cqe.Data().(int); ok {
+			if want, have := bytes.Repeat([]byte{byte(block)}, size), bufs[block]; !bytes.Equal(want, have) {
+				t.Fatalf("block %d: want %v, have %v", block, want, have)
+			}
+			reads++
+			<-inFlight
+		}
+		cqe.Seen()
+	}
+	for g := 0; g < goroutines; g++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package goliburing
+
+/*
+#include <string.h>
+#include <errno.h>
+*/
+import "C"
+
+import (
+	"context"
+	"fmt"
+)
+
+// ErrWaitCQECode Error codes returned while waiting for a completion queue event.
+type ErrWaitCQECode = int
+
+const (
+	// ErrWaitCQEETIME The timeout or context deadline expired before a completion was available.
+	ErrWaitCQEETIME ErrWaitCQECode = C.ETIME
+	// ErrWaitCQEECANCELED The context was cancelled before a completion was available.
+	ErrWaitCQEECANCELED = C.ECANCELED
+	// ErrWaitCQEEAGAIN No completion is available.
+	ErrWaitCQEEAGAIN = C.EAGAIN
+	// ErrWaitCQEEBUSY The completion queue overflowed and the kernel could not flush the overflown events.
+	ErrWaitCQEEBUSY = C.EBUSY
+)
+
+// ErrWaitCQE Represents an error while waiting for a completion queue event.
+type ErrWaitCQE struct {
+	Code     ErrWaitCQECode
+	Message  string
+	SubError error
+}
+
+// NewErrWaitCQE Creates a new wait CQE error from a negated errno.
+func NewErrWaitCQE(code C.int, subErr error) *ErrWaitCQE {
+	var message string
+	if code == -C.ETIME {
+		message = "timed out"
+	} else if code == -C.ECANCELED {
+		message = "cancelled"
+	} else {
+		message = fmt.Sprintf("code: %d, err: %s", -code, C.GoString(C.strerror(-code)))
+	}
+
+	return &ErrWaitCQE{
+		Code:     ErrWaitCQECode(-code),
+		Message:  message,
+		SubError: subErr,
+	}
+}
+
+func (e *ErrWaitCQE) Error() string {
+	if e.SubError != nil {
+		return fmt.Sprintf("wait cqe failed: %s: %s", e.Message, e.SubError.Error())
+	}
+
+	return fmt.Sprintf("wait cqe failed: %s", e.Message)
+}
+
+// Timeout Reports whether the wait failed because a timeout or deadline expired.
+func (e *ErrWaitCQE) Timeout() bool {
+	return e.Code == ErrWaitCQEETIME || e.SubError == context.DeadlineExceeded
+}
+
+// Unwrap Gets the context error that ended the wait, if any.
+func (e *ErrWaitCQE) Unwrap() error {
+	return e.SubError
+}
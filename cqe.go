@@ -4,11 +4,23 @@ package goliburing
 #include "liburing.h"
 */
 import "C"
+import "syscall"
 
 // CQE Represents a completion queue entry.
+// The result, user data and flags are copied out of the completion queue,
+// so they stay valid after the entry is marked as seen.
 type CQE struct {
-	ring *Ring
-	cqe  *C.struct_io_uring_cqe
+	ring     *Ring
+	res      int32
+	userData uint64
+	flags    uint32
+	data     interface{}
+	// last Set when this is the last completion of its SQE, which `Seen` then hands back to the ring.
+	last bool
+	// sockaddr, oobn and recvFlags Results of accept and recvmsg, decoded from their SQE.
+	sockaddr  syscall.Sockaddr
+	oobn      int
+	recvFlags int
 }
 
 func newCQE(ring *Ring) *CQE {
@@ -17,7 +29,127 @@ func newCQE(ring *Ring) *CQE {
 	}
 }
 
-// Seen sets the CQE as seen.
+// load Copies cqe out of the completion queue and takes the value associated with its SQE.
+// The SQE stays registered until the completion is marked as seen, so loading the same
+// completion again, such as by calling `Ring.PeekBatchCQE` twice, still finds its data.
+func (c *CQE) load(cqe *C.struct_io_uring_cqe) {
+	c.res = int32(cqe.res)
+	c.userData = uint64(cqe.user_data)
+	c.flags = uint32(cqe.flags)
+	c.data = nil
+	c.last = false
+	c.sockaddr = nil
+	c.oobn = 0
+	c.recvFlags = 0
+
+	// The SQE is read under the lock its owner released after preparing it.
+	c.ring.sqeMu.Lock()
+	defer c.ring.sqeMu.Unlock()
+	sqe, ok := c.ring.sqes.lookup(c.userData)
+	if !ok {
+		return
+	}
+
+	c.data = sqe.data
+	if !c.More() {
+		c.last = true
+		if c.res >= 0 {
+			sqe.decodeResult(c)
+		}
+	}
+}
+
+// Seen sets the CQE as seen, and hands its SQE back to the ring if this was its last completion.
+// The entry itself was already taken out of the completion queue when it was reaped.
+// Has no effect on entries copied out by `Ring.PeekBatchCQE`, use `Ring.CQAdvance` instead.
 func (c *CQE) Seen() {
-	C.io_uring_cqe_seen(c.ring.ring, c.cqe)
+	if c.last {
+		c.last = false
+		c.ring.releaseSQE(c.userData)
+	}
+}
+
+// Res Gets the raw result of the completed operation.
+// A negative value is the negated errno of a failed operation. See `Errno`.
+func (c *CQE) Res() int32 {
+	return c.res
+}
+
+// UserData Gets the user data of the submission this completion belongs to.
+func (c *CQE) UserData() uint64 {
+	return c.userData
+}
+
+// Data Gets the value associated with the submission through `SQE.SetData`,
+// or nil if there is none.
+func (c *CQE) Data() interface{} {
+	return c.data
+}
+
+// Flags Gets the completion flags. See `CQEFlag`.
+func (c *CQE) Flags() CQEFlag {
+	return CQEFlag(c.flags)
+}
+
+// More Reports whether more completions will follow for the same SQE, such as for a multishot poll.
+// The SQE stays in flight, and its data available, until the last completion.
+func (c *CQE) More() bool {
+	return c.Flags()&IORingCQEFMore != 0
+}
+
+// PollEvents Gets the events that are ready on the fd of a completed poll, see `PollEvent`.
+// Gets 0 if the poll failed or was removed.
+func (c *CQE) PollEvents() PollEvent {
+	if c.res < 0 {
+		return 0
+	}
+
+	return PollEvent(c.res)
+}
+
+// Sockaddr Gets the address of the peer of a completed accept, or the source address of
+// a completed recvmsg, or nil if there is none. See `SQE.PrepAccept` and `SQE.PrepRecvMsg`.
+func (c *CQE) Sockaddr() syscall.Sockaddr {
+	return c.sockaddr
+}
+
+// OOBN Gets the number of bytes of ancillary data received by a completed recvmsg.
+func (c *CQE) OOBN() int {
+	return c.oobn
+}
+
+// RecvFlags Gets the flags of a completed recvmsg, such as syscall.MSG_TRUNC.
+func (c *CQE) RecvFlags() int {
+	return c.recvFlags
+}
+
+// Errno Gets the error of a failed operation, or 0 if the operation succeeded.
+func (c *CQE) Errno() syscall.Errno {
+	if res := c.Res(); res < 0 {
+		return syscall.Errno(-res)
+	}
+
+	return 0
+}
+
+// Canceled Reports whether the operation was canceled before it completed,
+// such as an SQE of a `Chain` whose previous link failed.
+func (c *CQE) Canceled() bool {
+	return c.Errno() == syscall.ECANCELED
+}
+
+// TimedOut Reports whether a timeout expired, see `SQE.PrepTimeout` and `SQE.LinkTimeout`.
+func (c *CQE) TimedOut() bool {
+	return c.Errno() == syscall.ETIME
+}
+
+// Result Gets the result of the completed operation.
+// For reads and writes this is the number of bytes transferred.
+// Error will be of type `syscall.Errno` on failure.
+func (c *CQE) Result() (int, error) {
+	if errno := c.Errno(); errno != 0 {
+		return 0, errno
+	}
+
+	return int(c.Res()), nil
 }
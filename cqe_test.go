@@ -0,0 +1,257 @@
+package goliburing
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestCQEErrno(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	data := make([]byte, 16)
+	sqe, err := ring.GetEmptySQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqe.PrepRead(-1, data, 0)
+	ring.Submit()
+	cqe, err := ring.WaitCQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cqe.Seen()
+
+	if want, have := -int32(syscall.EBADF), cqe.Res(); want != have {
+		t.Fatalf("Res: want %d, have %d", want, have)
+	}
+	if want, have := syscall.EBADF, cqe.Errno(); want != have {
+		t.Fatalf("Errno: want %v, have %v", want, have)
+	}
+	if _, err := cqe.Result(); err != syscall.EBADF {
+		t.Fatalf("Result: want %v, have %v", syscall.EBADF, err)
+	}
+}
+
+func TestCQEData(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	type request struct {
+		name string
+	}
+
+	tokens := make(map[uint64]*request)
+	for _, name := range []string{"first", "second"} {
+		sqe, err := ring.GetEmptySQE()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sqe.PrepRead(-1, make([]byte, 16), 0)
+		req := &request{name: name}
+		tokens[sqe.SetData(req)] = req
+	}
+	ring.Submit()
+
+	for i := 0; i < len(tokens); i++ {
+		cqe, err := ring.WaitCQE()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, ok := cqe.Data().(*request)
+		if !ok {
+			t.Fatalf("Data: want *request, have %T", cqe.Data())
+		}
+		if want, have := tokens[cqe.UserData()], req; want != have {
+			t.Fatalf("Data: want %q, have %q", want.name, have.name)
+		}
+		cqe.Seen()
+	}
+
+	if want, have := 0, ring.InFlight(); want != have {
+		t.Fatalf("registered values: want %d, have %d", want, have)
+	}
+}
+
+func TestPeekCQE(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	_, err = ring.PeekCQE()
+	if e, ok := err.(*ErrWaitCQE); !ok || e.Code != ErrWaitCQEEAGAIN {
+		t.Fatalf("PeekCQE: want ErrWaitCQEEAGAIN, have %v", err)
+	}
+
+	sqe, err := ring.GetEmptySQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqe.PrepRead(-1, make([]byte, 16), 0)
+	sqe.SetData("peek")
+	ring.Submit()
+
+	cqe, err := ring.PeekCQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "peek", cqe.Data(); want != have {
+		t.Fatalf("Data: want %v, have %v", want, have)
+	}
+
+	// The completion was taken, so it is not peeked again, even before it is seen.
+	_, err = ring.PeekCQE()
+	if e, ok := err.(*ErrWaitCQE); !ok || e.Code != ErrWaitCQEEAGAIN {
+		t.Fatalf("second PeekCQE: want ErrWaitCQEEAGAIN, have %v", err)
+	}
+	cqe.Seen()
+
+	if want, have := 0, ring.InFlight(); want != have {
+		t.Fatalf("InFlight: want %d, have %d", want, have)
+	}
+}
+
+func TestPeekCQEWhileWaiting(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	sqe, err := ring.GetEmptySQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqe.PrepRead(-1, make([]byte, 16), 0)
+	sqe.SetData("waited")
+	if _, err := ring.SubmitAndWait(1); err != nil {
+		t.Fatal(err)
+	}
+
+	// As if another goroutine was about to block in the kernel, which needs the completion
+	// to stay in the completion queue to return.
+	ring.cqMu.Lock()
+	ring.reaping = true
+	ring.cqMu.Unlock()
+
+	if _, err := ring.PeekCQE(); err == nil {
+		t.Fatal("PeekCQE: want no completion while another goroutine waits")
+	}
+	if want, have := 0, ring.PeekBatchCQE(make([]CQE, 1)); want != have {
+		t.Fatalf("PeekBatchCQE: want %d, have %d", want, have)
+	}
+	ring.CQAdvance(1)
+	if ret := ring.waitCQ(0); ret < 0 {
+		t.Fatalf("waitCQ: want the completion still queued, have %d", ret)
+	}
+
+	ring.cqMu.Lock()
+	ring.reaping = false
+	ring.cqMu.Unlock()
+
+	cqe, err := ring.PeekCQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "waited", cqe.Data(); want != have {
+		t.Fatalf("Data: want %v, have %v", want, have)
+	}
+	cqe.Seen()
+}
+
+func TestPeekBatchCQE(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	const count = 4
+	for i := 0; i < count; i++ {
+		sqe, err := ring.GetEmptySQE()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sqe.PrepRead(-1, make([]byte, 16), 0)
+		sqe.SetData(i)
+	}
+	ring.Submit()
+
+	cqes := make([]CQE, 2*count)
+	n := ring.PeekBatchCQE(cqes)
+	if want, have := count, n; want != have {
+		t.Fatalf("PeekBatchCQE: want %d, have %d", want, have)
+	}
+
+	seen := make(map[interface{}]bool)
+	for _, cqe := range cqes[:n] {
+		if want, have := syscall.EBADF, cqe.Errno(); want != have {
+			t.Fatalf("Errno: want %v, have %v", want, have)
+		}
+		seen[cqe.Data()] = true
+	}
+	for i := 0; i < count; i++ {
+		if !seen[i] {
+			t.Fatalf("Data: missing %d", i)
+		}
+	}
+
+	if want, have := n, ring.PeekBatchCQE(cqes); want != have {
+		t.Fatalf("PeekBatchCQE again: want %d, have %d", want, have)
+	}
+	if cqes[0].Data() == nil {
+		t.Fatal("Data after second PeekBatchCQE: want value, have nil")
+	}
+
+	ring.CQAdvance(uint32(n))
+	if _, err := ring.PeekCQE(); err == nil {
+		t.Fatal("PeekCQE: want error after CQAdvance, have none")
+	}
+	if want, have := 0, ring.InFlight(); want != have {
+		t.Fatalf("InFlight: want %d, have %d", want, have)
+	}
+}
+
+func TestCQECanceled(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	for i := 0; i < 2; i++ {
+		sqe, err := ring.GetEmptySQE()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sqe.PrepRead(-1, make([]byte, 16), 0)
+		if i == 0 {
+			sqe.SetLink()
+		}
+	}
+	ring.Submit()
+
+	canceled := 0
+	for i := 0; i < 2; i++ {
+		cqe, err := ring.WaitCQE()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cqe.Canceled() {
+			canceled++
+		}
+		cqe.Seen()
+	}
+	if want, have := 1, canceled; want != have {
+		t.Fatalf("Canceled: want %d, have %d", want, have)
+	}
+}
@@ -0,0 +1,185 @@
+package goliburing
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// AppendSyncMode How a FileAppender makes its batches durable.
+type AppendSyncMode int
+
+const (
+	// AppendSyncFSync Follow each batch with an fsync(2) of the file.
+	AppendSyncFSync AppendSyncMode = iota
+	// AppendSyncFDataSync Follow each batch with an fdatasync(2) of the file,
+	// which skips metadata that is not needed to read the data back.
+	AppendSyncFDataSync
+	// AppendSyncFileRange Follow each batch with a sync_file_range(2) of the bytes it wrote.
+	// Cheaper than a full sync, but it neither flushes metadata nor the disk's write cache,
+	// so it only makes the batch durable on files whose size and blocks are already allocated.
+	AppendSyncFileRange
+)
+
+// syncFileRangeDurable Writes the range out and waits for it.
+const syncFileRangeDurable = SyncFileRangeWaitBefore | SyncFileRangeWrite | SyncFileRangeWaitAfter
+
+// maxAppendBatch The most appends written by a single vectored write, IOV_MAX on Linux.
+const maxAppendBatch = 1024
+
+// maxAppendBatchBytes The most bytes written by a single batch, unless a single append is larger.
+// Linux transfers at most about 2 GiB per write, so larger batches would always complete short.
+const maxAppendBatchBytes = 1 << 30
+
+// FileAppender Group commit on top of a FileWriterAsync.
+// Concurrent `Append` calls are collected into batches. Each batch is written with a single
+// vectored write at the writer's offset, followed by a linked sync of the file, and every
+// `Append` of the batch returns once the batch is durable. While a batch is being committed,
+// new appends queue up for the next one, so the cost of a sync is shared by all of them.
+// A FileAppender is safe for concurrent use. The writer should not be written to directly
+// while appends are in progress.
+type FileAppender struct {
+	writer *FileWriterAsync
+	mu     sync.Mutex
+	mode   AppendSyncMode
+	// pending Appends waiting for the next batch, oldest first.
+	pending []*appendRequest
+	// committing Set while an `Append` is committing a batch, which makes it the leader.
+	committing bool
+}
+
+// appendRequest A single `Append` call.
+type appendRequest struct {
+	data   []byte
+	offset int64
+	err    error
+	// leader Set when the request is handed the job of committing the next batch.
+	leader bool
+	// wake Closed once the request is durable or has become the leader.
+	wake chan struct{}
+}
+
+// NewFileAppender Create a new FileAppender writing through writer.
+// The writer's ring needs a queue depth of at least 2, for a write and its sync.
+func NewFileAppender(writer *FileWriterAsync) (*FileAppender, error) {
+	if writer == nil {
+		return nil, fmt.Errorf("writer not provided")
+	}
+	if writer.ring.QueueDepth() < 2 {
+		return nil, fmt.Errorf("queue depth %d too small", writer.ring.QueueDepth())
+	}
+
+	return &FileAppender{
+		writer: writer,
+		mode:   AppendSyncFSync,
+	}, nil
+}
+
+// SetSyncMode Sets how batches are made durable. Defaults to `AppendSyncFSync`.
+func (a *FileAppender) SetSyncMode(mode AppendSyncMode) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.mode = mode
+}
+
+// Append Append data to the file and wait until it is durable.
+// Returns the offset data was written at, or -1 if the writer uses the file's current position.
+// On error, none of the data of the batch it was part of can be assumed durable.
+// data must not be modified until Append returns.
+func (a *FileAppender) Append(data []byte) (int64, error) {
+	req := &appendRequest{
+		data: data,
+		wake: make(chan struct{}),
+	}
+
+	a.mu.Lock()
+	a.pending = append(a.pending, req)
+	leader := !a.committing
+	a.committing = true
+	a.mu.Unlock()
+
+	if !leader {
+		<-req.wake
+		if !req.leader {
+			return req.offset, req.err
+		}
+	}
+
+	a.mu.Lock()
+	n, size := 0, 0
+	for n < len(a.pending) && n < maxAppendBatch {
+		size += len(a.pending[n].data)
+		if n > 0 && size > maxAppendBatchBytes {
+			break
+		}
+		n++
+	}
+	batch := a.pending[:n:n]
+	a.pending = a.pending[n:]
+	mode := a.mode
+	a.mu.Unlock()
+
+	a.commit(req, batch, mode)
+
+	// Hand the next batch over to the oldest waiting append, so the leader does not
+	// keep committing for others.
+	a.mu.Lock()
+	if len(a.pending) > 0 {
+		next := a.pending[0]
+		next.leader = true
+		close(next.wake)
+	} else {
+		a.committing = false
+	}
+	a.mu.Unlock()
+
+	return req.offset, req.err
+}
+
+// commit Writes and syncs a batch, and wakes every request in it but the leader's.
+func (a *FileAppender) commit(leader *appendRequest, batch []*appendRequest, mode AppendSyncMode) {
+	bufs := make([][]byte, len(batch))
+	length := 0
+	for i, req := range batch {
+		bufs[i] = req.data
+		length += len(req.data)
+	}
+
+	a.writer.mu.Lock()
+	write, syncOp, err := a.writer.writeAndSync(bufs, mode)
+	a.writer.mu.Unlock()
+	if err == nil {
+		offset := write.Offset()
+		for _, req := range batch {
+			req.offset = offset
+			if offset != currentPosition {
+				offset += int64(len(req.data))
+			}
+		}
+		err = a.wait(write, syncOp, length)
+	}
+
+	for _, req := range batch {
+		if err != nil {
+			req.err = err
+		}
+		if req != leader {
+			close(req.wake)
+		}
+	}
+}
+
+// wait Waits for both the write and the sync of a batch of length bytes.
+func (a *FileAppender) wait(write *AsyncWrite, syncOp *AsyncWrite, length int) error {
+	n, writeErr := write.Wait()
+	_, syncErr := syncOp.Wait()
+	switch {
+	case writeErr != nil:
+		return writeErr
+	case n < length:
+		return io.ErrShortWrite
+	default:
+		return syncErr
+	}
+}
@@ -0,0 +1,115 @@
+package goliburing
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestFileAppender(t *testing.T) {
+	for _, mode := range []AppendSyncMode{AppendSyncFSync, AppendSyncFDataSync, AppendSyncFileRange} {
+		t.Run(fmt.Sprint(mode), func(t *testing.T) {
+			testFileAppender(t, mode)
+		})
+	}
+}
+
+func testFileAppender(t *testing.T, mode AppendSyncMode) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	f, err := os.OpenFile("/tmp/file-appender-test", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	writer, err := NewFileWriterAsync(ring, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appender, err := NewFileAppender(writer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appender.SetSyncMode(mode)
+
+	const appenders = 8
+	const appends = 32
+
+	type record struct {
+		data   []byte
+		offset int64
+	}
+	records := make(chan record, appenders*appends)
+	errs := make(chan error, appenders)
+	var wg sync.WaitGroup
+	for i := 0; i < appenders; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < appends; j++ {
+				data := []byte(fmt.Sprintf("appender %d record %d\n", i, j))
+				offset, err := appender.Append(data)
+				if err != nil {
+					errs <- err
+					return
+				}
+				records <- record{data, offset}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(records)
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	have, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := writer.Offset(); int64(len(have)) != want {
+		t.Fatalf("file size: want %d, have %d", want, len(have))
+	}
+
+	count := 0
+	for r := range records {
+		count++
+		end := r.offset + int64(len(r.data))
+		if r.offset < 0 || end > int64(len(have)) || !bytes.Equal(r.data, have[r.offset:end]) {
+			t.Fatalf("Append %q: not found at offset %d", r.data, r.offset)
+		}
+	}
+	if want := appenders * appends; count != want {
+		t.Fatalf("appends: want %d, have %d", want, count)
+	}
+	if want, have := 0, writer.InFlight(); want != have {
+		t.Fatalf("InFlight: want %d, have %d", want, have)
+	}
+}
+
+func TestFileAppenderQueueDepth(t *testing.T) {
+	ring, err := NewRing(1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	writer, err := NewFileWriterAsync(ring, os.Stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileAppender(writer); err == nil {
+		t.Fatal("NewFileAppender: want error for queue depth 1")
+	}
+}
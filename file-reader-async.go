@@ -0,0 +1,260 @@
+package goliburing
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// FileReaderAsync asynchronous file reader using an io_uring.
+// Sequential reads are served from blocks that are read ahead, so up to readAhead
+// block reads are in flight while the caller consumes earlier blocks.
+// A FileReaderAsync is safe for concurrent use. Its ring can be shared, since the reader only reaps
+// the completions of its own reads and leaves the others to their reapers.
+type FileReaderAsync struct {
+	mu         sync.Mutex
+	ring       *Ring
+	file       *os.File
+	blockSize  int
+	readAhead  int
+	waitPolicy WaitPolicy
+	// next The offset of the next block to read ahead.
+	next int64
+	// blocks Blocks read ahead, in file order.
+	blocks []*blockRead
+	// current The block `Read` is consuming, and the position within it.
+	current *blockRead
+	pos     int
+	// spare Buffers of consumed blocks, ready to be reused.
+	spare [][]byte
+}
+
+// blockRead A read submitted by a FileReaderAsync.
+type blockRead struct {
+	reader *FileReaderAsync
+	buf    []byte
+	offset int64
+	done   bool
+	n      int
+	err    error
+}
+
+var (
+	_ io.Reader   = (*FileReaderAsync)(nil)
+	_ io.ReaderAt = (*FileReaderAsync)(nil)
+)
+
+// NewFileReaderAsync Create a new FileReaderAsync that reads ahead up to readAhead blocks
+// of blockSize bytes. readAhead is capped at the ring's queue depth.
+// Reads start at offset 0.
+func NewFileReaderAsync(ring *Ring, file *os.File, blockSize int, readAhead int) (*FileReaderAsync, error) {
+	if ring == nil {
+		return nil, fmt.Errorf("ring not provided")
+	}
+	if blockSize <= 0 {
+		return nil, fmt.Errorf("invalid block size %d", blockSize)
+	}
+	if readAhead <= 0 {
+		return nil, fmt.Errorf("invalid read ahead %d", readAhead)
+	}
+	if uint32(readAhead) > ring.QueueDepth() {
+		readAhead = int(ring.QueueDepth())
+	}
+
+	return &FileReaderAsync{
+		ring:       ring,
+		file:       file,
+		blockSize:  blockSize,
+		readAhead:  readAhead,
+		waitPolicy: DefaultWaitPolicy,
+	}, nil
+}
+
+// SetWaitPolicy Sets how completions are waited for. See `WaitPolicy`.
+func (r *FileReaderAsync) SetWaitPolicy(policy WaitPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.waitPolicy = policy
+}
+
+// Read Read up to len(data) bytes from the file, reading the next blocks ahead.
+// Returns io.EOF at the end of the file. Implements `io.Reader`.
+func (r *FileReaderAsync) Read(data []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	for {
+		if b := r.current; b != nil && r.pos < b.n {
+			n := copy(data, b.buf[r.pos:b.n])
+			r.pos += n
+			return n, nil
+		}
+		r.recycle(r.current)
+		r.current = nil
+
+		if err := r.fill(); err != nil {
+			return 0, err
+		}
+
+		// The block stays queued until it has completed, so a failed wait is picked up by the next Read.
+		b := r.blocks[0]
+		if err := r.wait(b); err != nil {
+			return 0, err
+		}
+		r.blocks = r.blocks[1:]
+		if b.err != nil {
+			r.restart(b.offset)
+			return 0, b.err
+		}
+
+		if b.n < len(b.buf) {
+			// End of file, the blocks read ahead past it are of no use.
+			r.restart(b.offset + int64(b.n))
+			if b.n == 0 {
+				r.recycle(b)
+				return 0, io.EOF
+			}
+		}
+
+		r.current = b
+		r.pos = 0
+	}
+}
+
+// ReadAt Read len(data) bytes from the file at offset, without affecting `Read`.
+// Short reads are resubmitted, so an error is returned if and only if fewer than
+// len(data) bytes were read. Implements `io.ReaderAt`.
+func (r *FileReaderAsync) ReadAt(data []byte, offset int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if offset < 0 {
+		return 0, fmt.Errorf("negative offset %d", offset)
+	}
+
+	read := 0
+	for read < len(data) {
+		b := &blockRead{
+			reader: r,
+			buf:    data[read:],
+			offset: offset + int64(read),
+		}
+		if err := r.submit(b); err != nil {
+			return read, err
+		}
+		if err := r.wait(b); err != nil {
+			return read, err
+		}
+
+		read += b.n
+		if b.err != nil {
+			return read, b.err
+		}
+		if b.n == 0 {
+			return read, io.EOF
+		}
+	}
+
+	return read, nil
+}
+
+// fill Reads ahead until readAhead blocks are queued.
+func (r *FileReaderAsync) fill() error {
+	for len(r.blocks) < r.readAhead {
+		buf := make([]byte, r.blockSize)
+		if n := len(r.spare); n > 0 {
+			buf = r.spare[n-1]
+			r.spare = r.spare[:n-1]
+		}
+
+		b := &blockRead{
+			reader: r,
+			buf:    buf,
+			offset: r.next,
+		}
+		if err := r.submit(b); err != nil {
+			r.recycle(b)
+			if len(r.blocks) > 0 {
+				// Make do with the blocks already queued.
+				return nil
+			}
+			return err
+		}
+
+		r.blocks = append(r.blocks, b)
+		r.next += int64(r.blockSize)
+	}
+
+	return nil
+}
+
+// restart Drops the blocks read ahead and continues reading ahead from offset.
+func (r *FileReaderAsync) restart(offset int64) {
+	for _, b := range r.blocks {
+		if err := r.wait(b); err == nil {
+			r.recycle(b)
+		}
+	}
+	r.blocks = r.blocks[:0]
+	r.next = offset
+}
+
+// recycle Keeps the buffer of a consumed block for a later block.
+func (r *FileReaderAsync) recycle(b *blockRead) {
+	if b != nil && len(b.buf) == r.blockSize {
+		r.spare = append(r.spare, b.buf)
+	}
+}
+
+// submit Submits the read of b. If the submission fails, the SQE is discarded, unless it already
+// went out, see `SQE.Discard`, in which case b is in flight all the same, since the kernel takes it
+// with the next wait, and no error is returned.
+func (r *FileReaderAsync) submit(b *blockRead) error {
+	sqe, err := r.ring.getClaimedSQE(b)
+	if err != nil {
+		return err
+	}
+	sqe.PrepRead(int(r.file.Fd()), b.buf, uint64(b.offset))
+
+	if _, err := r.ring.submitSQEs(sqe); err != nil && sqe.Discard() == nil {
+		return err
+	}
+	return nil
+}
+
+// wait Reaps the completions of the reader's blocks until b has completed.
+func (r *FileReaderAsync) wait(b *blockRead) error {
+	for !b.done {
+		cqe, err := r.waitPolicy.reap(r.ring, r.owns)
+		if err != nil {
+			return err
+		}
+
+		completed := cqe.Data().(*blockRead)
+		if res := cqe.Res(); res < 0 {
+			completed.err = fmt.Errorf("Read failed %d", res)
+		} else {
+			completed.n = int(res)
+		}
+		completed.done = true
+		cqe.Seen()
+	}
+
+	return nil
+}
+
+// owns Reports whether sqe is a block read of the reader.
+func (r *FileReaderAsync) owns(sqe *SQE) bool {
+	if sqe == nil {
+		return false
+	}
+
+	b, ok := sqe.data.(*blockRead)
+	return ok && b.reader == r
+}
@@ -0,0 +1,173 @@
+package goliburing
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFileReaderAsync(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	f, err := os.OpenFile("/tmp/file-reader-async-test", os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	// Write and read back through the same ring.
+	writer, err := NewFileWriterAsync(ring, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []byte
+	for i := 0; i < 64; i++ {
+		data := bytes.Repeat([]byte{byte('a' + i%26)}, 100)
+		want = append(want, data...)
+		if _, err := writer.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := NewFileReaderAsync(ring, f, 512, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	have, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(want, have) {
+		t.Fatalf("ReadAll: want %d bytes, have %d bytes", len(want), len(have))
+	}
+
+	if n, err := reader.Read(make([]byte, 16)); n != 0 || err != io.EOF {
+		t.Fatalf("Read at end: want 0, EOF, have %d, %v", n, err)
+	}
+	if want, have := 0, ring.InFlight(); want != have {
+		t.Fatalf("InFlight after EOF: want %d, have %d", want, have)
+	}
+
+	// Data appended after EOF is picked up by the next Read.
+	if _, err := f.WriteAt([]byte("more"), int64(len(want))); err != nil {
+		t.Fatal(err)
+	}
+	more, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "more", string(more); want != have {
+		t.Fatalf("Read after append: want %q, have %q", want, have)
+	}
+}
+
+func TestFileReaderAsyncReadAt(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	f, err := os.OpenFile("/tmp/file-reader-async-read-at-test", os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := f.WriteString("hello, world"); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := NewFileReaderAsync(ring, f, 4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Interleave sequential reads, which read ahead, with ReadAt.
+	head := make([]byte, 3)
+	if _, err := io.ReadFull(reader, head); err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 5)
+	if _, err := reader.ReadAt(data, 7); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "world", string(data); want != have {
+		t.Fatalf("ReadAt: want %q, have %q", want, have)
+	}
+
+	rest, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "hello, world", string(head)+string(rest); want != have {
+		t.Fatalf("Read: want %q, have %q", want, have)
+	}
+
+	if _, err := reader.ReadAt(data, 10); err != io.EOF {
+		t.Fatalf("ReadAt past end: want EOF, have %v", err)
+	}
+}
+
+func TestFileReaderAsyncSharedRing(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	f, err := os.OpenFile("/tmp/file-reader-async-shared-ring-test", os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := f.WriteString("hello, world"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A completion that is not the reader's, which must be left on the ring.
+	sqe, err := ring.GetEmptySQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqe.PrepRead(-1, make([]byte, 16), 0)
+	sqe.SetData("foreign")
+	if _, err := ring.Submit(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := NewFileReaderAsync(ring, f, 4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	have, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "hello, world"; want != string(have) {
+		t.Fatalf("Read: want %q, have %q", want, have)
+	}
+
+	cqe, err := ring.WaitCQETimeout(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "foreign", cqe.Data(); want != have {
+		t.Fatalf("Data: want %v, have %v", want, have)
+	}
+	cqe.Seen()
+}
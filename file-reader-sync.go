@@ -0,0 +1,126 @@
+package goliburing
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// FileReaderSync synchronous file reader using an io_uring.
+// A FileReaderSync is safe for concurrent use. Its ring can be shared, since the reader only reaps
+// the completions of its own reads and leaves the others to their reapers.
+type FileReaderSync struct {
+	mu         sync.Mutex
+	ring       *Ring
+	file       *os.File
+	offset     int64
+	waitPolicy WaitPolicy
+}
+
+var (
+	_ io.Reader   = (*FileReaderSync)(nil)
+	_ io.ReaderAt = (*FileReaderSync)(nil)
+)
+
+// NewFileReaderSync Create a new FileReaderSync.
+// Reads start at offset 0.
+func NewFileReaderSync(ring *Ring, file *os.File) (*FileReaderSync, error) {
+	if ring == nil {
+		return nil, fmt.Errorf("ring not provided")
+	}
+
+	return &FileReaderSync{
+		ring:       ring,
+		file:       file,
+		offset:     0,
+		waitPolicy: DefaultWaitPolicy,
+	}, nil
+}
+
+// SetWaitPolicy Sets how reads wait for their completion. See `WaitPolicy`.
+func (r *FileReaderSync) SetWaitPolicy(policy WaitPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.waitPolicy = policy
+}
+
+// Offset Gets the offset the next `Read` starts at.
+func (r *FileReaderSync) Offset() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.offset
+}
+
+// Read Read up to len(data) bytes from the file and advance the offset by the number of bytes read.
+// Returns io.EOF at the end of the file. Implements `io.Reader`.
+func (r *FileReaderSync) Read(data []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	n, err := r.read(data, r.offset)
+	r.offset += int64(n)
+	if err == nil && n == 0 {
+		return 0, io.EOF
+	}
+
+	return n, err
+}
+
+// ReadAt Read len(data) bytes from the file at offset, leaving the reader's offset untouched.
+// Short reads are resubmitted, so an error is returned if and only if fewer than
+// len(data) bytes were read. Implements `io.ReaderAt`.
+func (r *FileReaderSync) ReadAt(data []byte, offset int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if offset < 0 {
+		return 0, fmt.Errorf("negative offset %d", offset)
+	}
+
+	read := 0
+	for read < len(data) {
+		n, err := r.read(data[read:], offset+int64(read))
+		read += n
+		if err != nil {
+			return read, err
+		}
+		if n == 0 {
+			return read, io.EOF
+		}
+	}
+
+	return read, nil
+}
+
+// read Submits a single read and waits for its completion.
+// If the submission fails, the SQE is discarded, unless it already went out, see `SQE.Discard`,
+// in which case the kernel takes it with the wait and its result is reported instead.
+func (r *FileReaderSync) read(data []byte, offset int64) (int, error) {
+	sqe, err := r.ring.getClaimedSQE(nil)
+	if err != nil {
+		return 0, err
+	}
+	sqe.PrepRead(int(r.file.Fd()), data, uint64(offset))
+	token := sqe.UserData()
+
+	if _, err := r.ring.submitSQEs(sqe); err != nil && sqe.Discard() == nil {
+		return 0, err
+	}
+
+	res, err := r.waitPolicy.waitFor(r.ring, token)
+	if err != nil {
+		return 0, err
+	}
+	if res < 0 {
+		return 0, fmt.Errorf("Read failed %d", res)
+	}
+
+	return int(res), nil
+}
@@ -0,0 +1,98 @@
+package goliburing
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestFileReaderSync(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	f, err := os.OpenFile("/tmp/file-reader-sync-test", os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	// Write and read back through the same ring.
+	writer, err := NewFileWriterSync(ring, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bytes.Repeat([]byte("0123456789"), 1000)
+	if _, err := writer.Write(want); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := NewFileReaderSync(ring, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	have, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(want, have) {
+		t.Fatalf("ReadAll: want %d bytes, have %d bytes", len(want), len(have))
+	}
+	if want, have := int64(len(want)), reader.Offset(); want != have {
+		t.Fatalf("Offset: want %d, have %d", want, have)
+	}
+
+	if n, err := reader.Read(make([]byte, 16)); n != 0 || err != io.EOF {
+		t.Fatalf("Read at end: want 0, EOF, have %d, %v", n, err)
+	}
+}
+
+func TestFileReaderSyncReadAt(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	f, err := os.OpenFile("/tmp/file-reader-sync-read-at-test", os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := f.WriteString("hello, world"); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := NewFileReaderSync(ring, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, 5)
+	n, err := reader.ReadAt(data, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "world", string(data[:n]); want != have {
+		t.Fatalf("ReadAt: want %q, have %q", want, have)
+	}
+
+	n, err = reader.ReadAt(data, 10)
+	if err != io.EOF {
+		t.Fatalf("ReadAt past end: want EOF, have %v", err)
+	}
+	if want, have := "ld", string(data[:n]); want != have {
+		t.Fatalf("ReadAt past end: want %q, have %q", want, have)
+	}
+
+	if want, have := int64(0), reader.Offset(); want != have {
+		t.Fatalf("Offset: want %d, have %d", want, have)
+	}
+}
@@ -1,108 +1,502 @@
 package goliburing
 
-/*
-#include <stdlib.h>
-#include <string.h>
-#include <sys/time.h>
-#include "liburing.h"
-
-struct io_data {
-	off_t  offset;
-	size_t len;
-	struct iovec iov;
-};
-
-struct io_data data;
-struct io_uring_sqe *sqe;
-int write_and_submit(struct io_uring *ring, void* buf, size_t len, off_t offset, int fd) {
-	data.offset = offset;
-	data.len = len;
-	data.iov.iov_base = buf;
-	data.iov.iov_len = len;
-
-	sqe = io_uring_get_sqe(ring);
-	if (!sqe) {
-    return -2; // Submission queue full.
-	}
-
-	io_uring_prep_writev(sqe, fd, &data.iov, 1, data.offset);
-
-	// io_uring_prep_rw(IORING_OP_WRITEV, sqe, fd, &data->iov, 1, data->offset);
-	// sqe->opcode = IORING_OP_WRITEV;
-	// sqe->flags = 0;
-	// sqe->ioprio = 0;
-	// sqe->fd = fd;
-	// sqe->off = data->offset;
-	// sqe->addr = (unsigned long)  &data->iov;
-	// sqe->len = 1;
-	// sqe->rw_flags = 0;
-	// sqe->user_data = (unsigned long) data;
-	// sqe->__pad2[0] = sqe->__pad2[1] = sqe->__pad2[2] = 0;
-
-	io_uring_sqe_set_data(sqe, &data);
-	io_uring_submit(ring);
-
-	return 0;
-}
-
-struct io_uring_cqe *cqe;
-int get_completion(struct io_uring *ring) {
-	while(1) {
-		int ret = io_uring_peek_cqe(ring, &cqe);
-		if (ret == 0) {
-			io_uring_cq_advance(ring, 1);
-			break;
-		}
-	}
-
-	return cqe->res;
-}
-*/
-import "C"
 import (
 	"fmt"
+	"io"
+	"math"
 	"os"
-	"unsafe"
+	"sync"
 )
 
 // FileWriterAsync asynchronous file writer using an io_uring.
+// Up to the ring's queue depth writes are kept in flight at once, and each completion
+// is matched to its write through the user data of its submission.
+// A FileWriterAsync is safe for concurrent use. Its ring can be shared, since the writer only reaps
+// the completions of its own writes and leaves the others to their reapers.
 type FileWriterAsync struct {
-	ring   *Ring
-	file   *os.File
-	offset int
+	// mu Guards submissions and the writer's state.
+	mu sync.Mutex
+	// reapMu Serializes reaping completions, so submitting never waits on the kernel.
+	reapMu     sync.Mutex
+	ring       *Ring
+	file       *os.File
+	offset     int64
+	curPosMode bool
+	waitPolicy WaitPolicy
+	// inFlight Writes that were submitted and have not completed yet, oldest first.
+	inFlight []*AsyncWrite
+	// err Set when a write failed after later writes were submitted past it, see `rewind`.
+	err error
+}
+
+// AsyncWrite A write submitted by `FileWriterAsync.Write`.
+type AsyncWrite struct {
+	writer *FileWriterAsync
+	// op Names the operation in errors.
+	op string
+	// data The buffer to write, the rest of which is resubmitted after a short write.
+	data   []byte
+	offset int64
+	// length The number of bytes the write was submitted for.
+	length int
+	// retry Set when the rest of a short write is resubmitted, instead of completing short.
+	retry bool
+	// reserved Set when the write advanced the writer's offset, which is pulled back if it fails.
+	reserved bool
+	// done Closed once n and err are set.
+	done chan struct{}
+	n    int
+	err  error
+	// failed Set when waiting for the write failed in the background, see `fail`.
+	failed bool
+	// background Starts waiting for the write in the background the first time `Done` is called.
+	background sync.Once
 }
 
 // NewFileWriterAsync Create a new FileWriterAsync.
+// Writes start at offset 0, see `UseCurrentPosition` to write at the file's current position instead.
 func NewFileWriterAsync(ring *Ring, file *os.File) (*FileWriterAsync, error) {
 	if ring == nil {
 		return nil, fmt.Errorf("ring not provided")
 	}
 
 	return &FileWriterAsync{
-		ring:   ring,
-		file:   file,
-		offset: 0,
+		ring:       ring,
+		file:       file,
+		offset:     0,
+		waitPolicy: DefaultWaitPolicy,
 	}, nil
 }
 
-// Write data to the file.
-func (r *FileWriterAsync) Write(data []byte) error {
-	p := unsafe.Pointer(&data[0])
-	l := C.size_t(len(data))
-	ret := C.write_and_submit(r.ring.ring, p, l, C.off_t(r.offset), C.int(r.file.Fd()))
-	if ret != 0 {
-		return fmt.Errorf("Write failed %d", ret)
+// SetWaitPolicy Sets how completions are waited for. See `WaitPolicy`.
+func (r *FileWriterAsync) SetWaitPolicy(policy WaitPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.waitPolicy = policy
+}
+
+// UseCurrentPosition Makes `Write` use and update the file's current position, like write(2),
+// instead of the offset tracked by the writer. Fails if the kernel does not support `IORingFeatRWCurPos`.
+// Writes in flight together may then land in any order.
+func (r *FileWriterAsync) UseCurrentPosition() error {
+	if !r.ring.hasFeature(IORingFeatRWCurPos) {
+		return fmt.Errorf("current position not supported")
 	}
 
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.curPosMode = true
 	return nil
 }
 
-// WaitForCompletion todo
+// Offset Gets the offset the next `Write` lands at.
+func (r *FileWriterAsync) Offset() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.offset
+}
+
+// InFlight Gets the number of writes that were submitted and have not completed yet.
+func (r *FileWriterAsync) InFlight() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.inFlight)
+}
+
+// Write Submit a write of data to the file. If the ring's queue depth worth of writes
+// are already in flight, Write first waits for the oldest one to complete.
+// The offset is advanced by len(data) as soon as the write is submitted,
+// so writes that are in flight together land back to back. The rest of a short write
+// is resubmitted, so the write completes with len(data) bytes or an error. If it fails,
+// the offset is pulled back to the end of the bytes it did write, unless later writes were
+// already submitted past it, in which case every later Write fails, since it would leave a hole.
+// data must not be modified until the write has completed.
+func (r *FileWriterAsync) Write(data []byte) (*AsyncWrite, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.curPosMode {
+		return r.write(data, currentPosition)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	w, err := r.write(data, r.offset)
+	if err != nil {
+		return nil, err
+	}
+
+	w.reserved = true
+	r.offset += int64(len(data))
+	return w, nil
+}
+
+// WriteAt Submit a write of data to the file at offset, leaving the writer's offset untouched.
+// data must not be modified until the write has completed.
+func (r *FileWriterAsync) WriteAt(data []byte, offset int64) (*AsyncWrite, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.write(data, offset)
+}
+
+// write Submits a write, r.mu must be held.
+func (r *FileWriterAsync) write(data []byte, offset int64) (*AsyncWrite, error) {
+	if err := r.reserve(1); err != nil {
+		return nil, err
+	}
+
+	w := r.newAsyncWrite("Write", data, offset)
+	w.length = len(data)
+	w.retry = offset != currentPosition
+	sqe, err := r.ring.getClaimedSQE(w)
+	if err != nil {
+		return nil, err
+	}
+	if err := sqe.PrepWriteV(int(r.file.Fd()), [][]byte{data}, uint64(offset)); err != nil {
+		sqe.Discard()
+		return nil, err
+	}
+
+	if err := r.submit([]*AsyncWrite{w}, []*SQE{sqe}); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// resubmit Submits the rest of a short write, which stays in flight, r.mu must be held.
+func (r *FileWriterAsync) resubmit(w *AsyncWrite) error {
+	sqe, err := r.ring.getClaimedSQE(w)
+	if err != nil {
+		return err
+	}
+	rest := w.data[w.n:]
+	if err := sqe.PrepWriteV(int(r.file.Fd()), [][]byte{rest}, uint64(w.offset+int64(w.n))); err != nil {
+		sqe.Discard()
+		return err
+	}
+
+	if _, err := r.ring.submitSQEs(sqe); err != nil && sqe.Discard() == nil {
+		return err
+	}
+	return nil
+}
+
+// rewind Pulls the writer's offset back to the end of the n bytes a failed or short write wrote,
+// if no write was submitted past it since. Otherwise those writes already left a hole, and every
+// later `Write` fails. r.mu must be held.
+func (r *FileWriterAsync) rewind(w *AsyncWrite, n int) {
+	if r.offset == w.offset+int64(w.length) {
+		r.offset = w.offset + int64(n)
+		return
+	}
+
+	if r.err == nil {
+		r.err = fmt.Errorf("Write at %d failed after %d of %d bytes, with later writes past it", w.offset, n, w.length)
+	}
+}
+
+// submit Submits sqes and puts their writes in flight, r.mu must be held.
+// If the submission fails, the SQEs are discarded, unless the kernel already has them,
+// in which case their writes are still put in flight, to be reaped once they complete.
+func (r *FileWriterAsync) submit(writes []*AsyncWrite, sqes []*SQE) error {
+	_, err := r.ring.submitSQEs(sqes...)
+	for i, sqe := range sqes {
+		if err != nil && sqe.Discard() == nil {
+			continue
+		}
+		r.inFlight = append(r.inFlight, writes[i])
+	}
+
+	return err
+}
+
+// writeAndSync Submits a vectored write of bufs at the writer's offset, linked to a sync of the file,
+// and advances the offset, which is pulled back like for `Write` if the write fails or is short.
+// The sync only starts once the whole write has completed, and completes with -ECANCELED if
+// the write fails or is short. r.mu must be held.
+func (r *FileWriterAsync) writeAndSync(bufs [][]byte, mode AppendSyncMode) (*AsyncWrite, *AsyncWrite, error) {
+	if r.err != nil && !r.curPosMode {
+		return nil, nil, r.err
+	}
+	if err := r.reserve(2); err != nil {
+		return nil, nil, err
+	}
+
+	length := 0
+	for _, buf := range bufs {
+		length += len(buf)
+	}
+	offset := r.offset
+	if r.curPosMode {
+		offset = currentPosition
+	}
+
+	write := r.newAsyncWrite("Write", nil, offset)
+	write.length = length
+	write.reserved = !r.curPosMode
+	syncOp := r.newAsyncWrite("Sync", nil, offset)
+
+	// The SQEs are taken together, so that they go out next to each other.
+	sqes, err := r.ring.getClaimedSQEs(write, syncOp)
+	if err != nil {
+		return nil, nil, err
+	}
+	writeSQE, syncSQE := sqes[0], sqes[1]
+
+	fd := int(r.file.Fd())
+	if err := writeSQE.PrepWriteV(fd, bufs, uint64(offset)); err != nil {
+		writeSQE.Discard()
+		syncSQE.Discard()
+		return nil, nil, err
+	}
+	writeSQE.SetLink()
+
+	switch {
+	case mode == AppendSyncFileRange && offset != currentPosition && length <= math.MaxUint32:
+		syncSQE.PrepSyncFileRange(fd, uint64(offset), uint32(length), syncFileRangeDurable)
+	case mode == AppendSyncFileRange && offset != currentPosition:
+		// The length does not fit, sync up to the end of the file instead.
+		syncSQE.PrepSyncFileRange(fd, uint64(offset), 0, syncFileRangeDurable)
+	case mode == AppendSyncFileRange:
+		syncSQE.PrepSyncFileRange(fd, 0, 0, syncFileRangeDurable)
+	default:
+		syncSQE.PrepFsync(fd, mode == AppendSyncFDataSync)
+	}
+
+	if err := r.submit([]*AsyncWrite{write, syncOp}, []*SQE{writeSQE, syncSQE}); err != nil {
+		return nil, nil, err
+	}
+
+	if !r.curPosMode {
+		r.offset += int64(length)
+	}
+	return write, syncOp, nil
+}
+
+// reserve Waits until n more writes can be put in flight, r.mu must be held.
+func (r *FileWriterAsync) reserve(n int) error {
+	for len(r.inFlight) > 0 && uint32(len(r.inFlight)+n) > r.ring.QueueDepth() {
+		oldest := r.inFlight[0]
+		r.mu.Unlock()
+		_, err := oldest.Wait()
+		r.mu.Lock()
+		if _, ok := err.(*ErrWaitCQE); ok {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *FileWriterAsync) newAsyncWrite(op string, data []byte, offset int64) *AsyncWrite {
+	return &AsyncWrite{
+		writer: r,
+		op:     op,
+		data:   data,
+		offset: offset,
+		done:   make(chan struct{}),
+	}
+}
+
+// Sync Commit the data of every write submitted so far to stable storage, with an fsync(2) through the ring.
+// The fsync is submitted as a barrier, so it only starts once the writes in flight have completed.
+func (r *FileWriterAsync) Sync() error {
+	r.mu.Lock()
+	w, err := r.sync()
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Wait()
+	return err
+}
+
+// sync Submits a drained fsync, r.mu must be held.
+func (r *FileWriterAsync) sync() (*AsyncWrite, error) {
+	if err := r.reserve(1); err != nil {
+		return nil, err
+	}
+
+	w := r.newAsyncWrite("Sync", nil, r.offset)
+	sqe, err := r.ring.getClaimedSQE(w)
+	if err != nil {
+		return nil, err
+	}
+	sqe.PrepFsync(int(r.file.Fd()), false)
+	sqe.SetDrain()
+
+	if err := r.submit([]*AsyncWrite{w}, []*SQE{sqe}); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// WaitForCompletion Wait for the oldest write in flight to complete.
+// Returns number of bytes written or an error.
 func (r *FileWriterAsync) WaitForCompletion() (int, error) {
-	ret := int(C.get_completion(r.ring.ring))
-	if ret < 0 {
-		return 0, fmt.Errorf("Write failed %d", ret)
+	r.mu.Lock()
+	if len(r.inFlight) == 0 {
+		r.mu.Unlock()
+		return 0, fmt.Errorf("no writes in flight")
+	}
+	oldest := r.inFlight[0]
+	r.mu.Unlock()
+
+	return oldest.Wait()
+}
+
+// Flush Wait for every write in flight to complete.
+// Returns the first error of those writes.
+func (r *FileWriterAsync) Flush() error {
+	r.mu.Lock()
+	inFlight := append([]*AsyncWrite(nil), r.inFlight...)
+	r.mu.Unlock()
+
+	var firstErr error
+	for _, w := range inFlight {
+		if _, err := w.Wait(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// reap Reaps a single completion of the writer and completes the write it belongs to,
+// or resubmits the rest of it after a short write. r.reapMu must be held.
+// The completions of others are left on the ring.
+func (r *FileWriterAsync) reap() error {
+	r.mu.Lock()
+	policy := r.waitPolicy
+	r.mu.Unlock()
+
+	cqe, err := policy.reap(r.ring, r.owns)
+	if err != nil {
+		return err
+	}
+	w := cqe.Data().(*AsyncWrite)
+	res := cqe.Res()
+	cqe.Seen()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if w.failed {
+		// The write was already completed with the error of its wait, so only the offset is settled.
+		n := w.n
+		if res > 0 {
+			n += int(res)
+		}
+		if w.reserved && n < w.length {
+			r.rewind(w, n)
+		}
+		r.dropInFlight(w)
+		return nil
+	}
+
+	if res < 0 {
+		w.err = fmt.Errorf("%s failed %d", w.op, res)
+	} else {
+		w.n += int(res)
+		if w.retry && w.n < w.length {
+			if res == 0 {
+				w.err = io.ErrShortWrite
+			} else if w.err = r.resubmit(w); w.err == nil {
+				return nil
+			}
+		}
 	}
+	if w.reserved && (w.err != nil || w.n < w.length) {
+		r.rewind(w, w.n)
+	}
+	r.dropInFlight(w)
+
+	close(w.done)
+	return nil
+}
+
+// fail Completes w with err, the error of waiting for it, unless it completed meanwhile. r.mu must be held.
+// w stays in flight until its completion is reaped, which then only settles the writer's offset.
+func (r *FileWriterAsync) fail(w *AsyncWrite, err error) {
+	select {
+	case <-w.done:
+		return
+	default:
+	}
+
+	w.err = err
+	w.failed = true
+	close(w.done)
+}
 
-	return ret, nil
+// dropInFlight Takes w off the writes in flight, r.mu must be held.
+func (r *FileWriterAsync) dropInFlight(w *AsyncWrite) {
+	for i, inFlight := range r.inFlight {
+		if inFlight == w {
+			r.inFlight = append(r.inFlight[:i], r.inFlight[i+1:]...)
+			return
+		}
+	}
+}
+
+// owns Reports whether sqe is a write or sync of the writer.
+func (r *FileWriterAsync) owns(sqe *SQE) bool {
+	if sqe == nil {
+		return false
+	}
+
+	w, ok := sqe.data.(*AsyncWrite)
+	return ok && w.writer == r
+}
+
+// Offset Gets the offset the write was submitted at, or -1 for the file's current position.
+func (w *AsyncWrite) Offset() int64 {
+	return w.offset
+}
+
+// Done Gets a channel that is closed once the write has completed.
+// The first call starts waiting for the write in the background, so the channel
+// is closed even if nothing else waits for it. If that wait fails, its error is
+// recorded on the write, see `Wait`, and the channel is closed all the same.
+func (w *AsyncWrite) Done() <-chan struct{} {
+	w.background.Do(func() {
+		go func() {
+			if _, err := w.Wait(); err != nil {
+				w.writer.mu.Lock()
+				w.writer.fail(w, err)
+				w.writer.mu.Unlock()
+			}
+		}()
+	})
+	return w.done
+}
+
+// Wait Wait for the write to complete, reaping the completions of other writes along the way.
+// Returns number of bytes written or an error.
+func (w *AsyncWrite) Wait() (int, error) {
+	for {
+		select {
+		case <-w.done:
+			return w.n, w.err
+		default:
+		}
+
+		w.writer.reapMu.Lock()
+		select {
+		case <-w.done:
+		default:
+			if err := w.writer.reap(); err != nil {
+				w.writer.reapMu.Unlock()
+				return 0, err
+			}
+		}
+		w.writer.reapMu.Unlock()
+	}
 }
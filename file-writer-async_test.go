@@ -1,8 +1,13 @@
 package goliburing
 
 import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestFileWriterAasync(t *testing.T) {
@@ -23,7 +28,7 @@ func TestFileWriterAasync(t *testing.T) {
 	}
 
 	data := make([]byte, 256)
-	err = writer.Write(data)
+	_, err = writer.Write(data)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -59,7 +64,7 @@ func BenchmarkFileWriterAasync(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		err := writer.Write(data)
+		_, err := writer.Write(data)
 		if err != nil {
 			b.Fatal(err)
 		}
@@ -71,3 +76,430 @@ func BenchmarkFileWriterAasync(b *testing.B) {
 	b.StopTimer()
 	os.Remove(f.Name())
 }
+
+func TestFileWriterAsyncConcurrent(t *testing.T) {
+	const writers = 8
+	const writes = 64
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			ring, err := NewRing(8, nil)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer ring.Destroy()
+
+			f, err := os.OpenFile(fmt.Sprintf("/tmp/file-writer-async-concurrent-%d", i), os.O_CREATE|os.O_TRUNC|os.O_APPEND|os.O_WRONLY, 0644)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer os.Remove(f.Name())
+			defer f.Close()
+
+			writer, err := NewFileWriterAsync(ring, f)
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			data := make([]byte, 128)
+			for k := 0; k < writes; k++ {
+				if _, err := writer.Write(data); err != nil {
+					errs <- err
+					return
+				}
+				n, err := writer.WaitForCompletion()
+				if err != nil {
+					errs <- err
+					return
+				}
+				if n != len(data) {
+					errs <- fmt.Errorf("Write: want %d, have %d", len(data), n)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestFileWriterAsyncOffset(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	f, err := os.OpenFile("/tmp/file-writer-async-offset-test", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	writer, err := NewFileWriterAsync(ring, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunks := []string{"hello", ", ", "world"}
+	for _, data := range chunks {
+		if _, err := writer.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := writer.WriteAt([]byte("!"), 12); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(chunks)+1; i++ {
+		if _, err := writer.WaitForCompletion(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if want, have := int64(12), writer.Offset(); want != have {
+		t.Fatalf("Offset: want %d, have %d", want, have)
+	}
+
+	have, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "hello, world!"; want != string(have) {
+		t.Fatalf("file: want %q, have %q", want, have)
+	}
+}
+
+func TestFileWriterAsyncOffsetFailed(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	f, err := os.OpenFile("/tmp/file-writer-async-offset-failed-test", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	writer, err := NewFileWriterAsync(ring, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := writer.Write([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Writes to a closed file fail, and the offset only counts the bytes written.
+	f.Close()
+	w, err = writer.Write([]byte("world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Wait(); err == nil {
+		t.Fatal("Wait: want error for a closed file")
+	}
+	if want, have := int64(5), writer.Offset(); want != have {
+		t.Fatalf("Offset after failed write: want %d, have %d", want, have)
+	}
+
+	// The first of two failed writes leaves a hole the second one was submitted past.
+	for _, data := range []string{"a", "b"} {
+		if _, err := writer.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Flush(); err == nil {
+		t.Fatal("Flush: want error for a closed file")
+	}
+	if _, err := writer.Write([]byte("c")); err == nil {
+		t.Fatal("Write after a hole: want error")
+	}
+}
+
+func TestFileWriterAsyncFailedWait(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	f, err := os.OpenFile("/tmp/file-writer-async-failed-wait-test", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	writer, err := NewFileWriterAsync(ring, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := writer.Write([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	// As if waiting in the background failed before the write to the closed file completed.
+	f.Close()
+	w, err = writer.Write([]byte("world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitErr := fmt.Errorf("wait failed")
+	writer.mu.Lock()
+	writer.fail(w, waitErr)
+	writer.mu.Unlock()
+
+	select {
+	case <-w.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Done: not closed")
+	}
+	if _, err := w.Wait(); err != waitErr {
+		t.Fatalf("Wait: want %v, have %v", waitErr, err)
+	}
+
+	// Its completion still settles the offset, without touching the write.
+	writer.reapMu.Lock()
+	err = writer.reap()
+	writer.reapMu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Wait(); err != waitErr {
+		t.Fatalf("Wait after completion: want %v, have %v", waitErr, err)
+	}
+	if want, have := int64(5), writer.Offset(); want != have {
+		t.Fatalf("Offset: want %d, have %d", want, have)
+	}
+	if want, have := 0, ring.InFlight(); want != have {
+		t.Fatalf("InFlight: want %d, have %d", want, have)
+	}
+}
+
+func TestFileWriterAsyncWaitPolicy(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	f, err := os.OpenFile("/tmp/file-writer-async-wait-policy-test", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	writer, err := NewFileWriterAsync(ring, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, 256)
+	for _, policy := range []WaitPolicy{DefaultWaitPolicy, {Spin: 1000}} {
+		writer.SetWaitPolicy(policy)
+		if _, err := writer.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		n, err := writer.WaitForCompletion()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, have := len(data), n; want != have {
+			t.Fatalf("Write with %+v: want %d, have %d", policy, want, have)
+		}
+	}
+}
+
+func TestFileWriterAsyncPipelined(t *testing.T) {
+	var queueDepth uint32 = 4
+	ring, err := NewRing(queueDepth, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	f, err := os.OpenFile("/tmp/file-writer-async-pipelined-test", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	writer, err := NewFileWriterAsync(ring, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const count = 32
+	var want []byte
+	writes := make([]*AsyncWrite, count)
+	for i := 0; i < count; i++ {
+		data := bytes.Repeat([]byte{byte('a' + i%26)}, 64)
+		want = append(want, data...)
+
+		writes[i], err = writer.Write(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inFlight := writer.InFlight(); uint32(inFlight) > queueDepth {
+			t.Fatalf("InFlight: want at most %d, have %d", queueDepth, inFlight)
+		}
+	}
+
+	// Wait out of order from several goroutines, completions are matched to their writes.
+	var wg sync.WaitGroup
+	errs := make(chan error, count)
+	for i := count - 1; i >= 0; i-- {
+		wg.Add(1)
+		go func(w *AsyncWrite) {
+			defer wg.Done()
+			n, err := w.Wait()
+			if err != nil {
+				errs <- err
+				return
+			}
+			if n != 64 {
+				errs <- fmt.Errorf("Wait at offset %d: want %d, have %d", w.Offset(), 64, n)
+			}
+		}(writes[i])
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if want, have := 0, writer.InFlight(); want != have {
+		t.Fatalf("InFlight: want %d, have %d", want, have)
+	}
+
+	have, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(want, have) {
+		t.Fatalf("file: want %q, have %q", want, have)
+	}
+}
+
+func TestFileWriterAsyncSync(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	f, err := os.OpenFile("/tmp/file-writer-async-sync-test", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	writer, err := NewFileWriterAsync(ring, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The sync waits for the writes still in flight.
+	writes := make([]*AsyncWrite, 4)
+	for i := range writes {
+		writes[i], err = writer.Write(bytes.Repeat([]byte{'a'}, 256))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	for i, w := range writes {
+		if n, err := w.Wait(); err != nil || n != 256 {
+			t.Fatalf("write %d: want 256, <nil>, have %d, %v", i, n, err)
+		}
+	}
+	if want, have := 0, writer.InFlight(); want != have {
+		t.Fatalf("InFlight: want %d, have %d", want, have)
+	}
+
+	// An fsync of a bad fd fails through the ring.
+	f.Close()
+	if err := writer.Sync(); err == nil {
+		t.Fatal("Sync of closed file: want error")
+	}
+}
+
+func TestFileWriterAsyncSharedRing(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	f, err := os.OpenFile("/tmp/file-writer-async-shared-ring-test", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	writer, err := NewFileWriterAsync(ring, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A completion that is not the writer's, which must be left on the ring.
+	sqe, err := ring.GetEmptySQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqe.PrepRead(-1, make([]byte, 16), 0)
+	sqe.SetData("foreign")
+	if _, err := ring.Submit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Done is closed without anything waiting for the write.
+	w, err := writer.Write([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-w.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Done: not closed")
+	}
+	if n, err := w.Wait(); err != nil || n != 5 {
+		t.Fatalf("Wait: want 5, <nil>, have %d, %v", n, err)
+	}
+
+	cqe, err := ring.WaitCQETimeout(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "foreign", cqe.Data(); want != have {
+		t.Fatalf("Data: want %v, have %v", want, have)
+	}
+	cqe.Seen()
+}
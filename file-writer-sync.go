@@ -1,94 +1,201 @@
 package goliburing
 
-/*
-#include <stdlib.h>
-#include <string.h>
-#include <sys/time.h>
-#include "liburing.h"
-
-struct io_data {
-	off_t  offset;
-	size_t len;
-	struct iovec iov;
-};
-
-struct io_data data;
-struct io_uring_cqe *cqe;
-struct io_uring_sqe *sqe;
-int write_and_submit_and_wait(struct io_uring *ring, void* buf, size_t len, off_t offset, int fd) {
-	data.offset = offset;
-	data.len = len;
-	data.iov.iov_base = buf;
-	data.iov.iov_len = len;
-
-	sqe = io_uring_get_sqe(ring);
-	if (!sqe) {
-    return -2; // Submission queue full.
-	}
-
-	io_uring_prep_writev(sqe, fd, &data.iov, 1, data.offset);
-
-	// io_uring_prep_rw(IORING_OP_WRITEV, sqe, fd, &data->iov, 1, data->offset);
-	// sqe->opcode = IORING_OP_WRITEV;
-	// sqe->flags = 0;
-	// sqe->ioprio = 0;
-	// sqe->fd = fd;
-	// sqe->off = data->offset;
-	// sqe->addr = (unsigned long)  &data->iov;
-	// sqe->len = 1;
-	// sqe->rw_flags = 0;
-	// sqe->user_data = (unsigned long) data;
-	// sqe->__pad2[0] = sqe->__pad2[1] = sqe->__pad2[2] = 0;
-
-	io_uring_sqe_set_data(sqe, &data);
-	io_uring_submit(ring);
-
-	while(1) {
-		int ret = io_uring_peek_cqe(ring, &cqe);
-		if (ret == 0) {
-			io_uring_cq_advance(ring, 1);
-			break;
-		}
-	}
-
-	return cqe->res;
-}
-*/
-import "C"
 import (
 	"fmt"
+	"io"
 	"os"
-	"unsafe"
+	"sync"
 )
 
+// currentPosition The offset that makes reads and writes use and update the file's current position.
+// Requires `IORingFeatRWCurPos`.
+const currentPosition int64 = -1
+
 // FileWriterSync synchronous file writer using an io_uring.
+// A FileWriterSync is safe for concurrent use. Its ring can be shared, since the writer only reaps
+// the completions of its own writes and leaves the others to their reapers.
 type FileWriterSync struct {
-	ring   *Ring
-	file   *os.File
-	offset int
+	mu         sync.Mutex
+	ring       *Ring
+	file       *os.File
+	offset     int64
+	curPosMode bool
+	closed     bool
+	waitPolicy WaitPolicy
 }
 
+var (
+	_ io.Writer   = (*FileWriterSync)(nil)
+	_ io.WriterAt = (*FileWriterSync)(nil)
+	_ io.Closer   = (*FileWriterSync)(nil)
+)
+
 // NewFileWriterSync Create a new FileWriterSync.
+// Writes start at offset 0, see `UseCurrentPosition` to write at the file's current position instead.
 func NewFileWriterSync(ring *Ring, file *os.File) (*FileWriterSync, error) {
 	if ring == nil {
 		return nil, fmt.Errorf("ring not provided")
 	}
 
 	return &FileWriterSync{
-		ring:   ring,
-		file:   file,
-		offset: 0,
+		ring:       ring,
+		file:       file,
+		offset:     0,
+		waitPolicy: DefaultWaitPolicy,
 	}, nil
 }
 
-// Write the data to the file. Returns number of bytes written or an error.
+// SetWaitPolicy Sets how writes wait for their completion. See `WaitPolicy`.
+func (r *FileWriterSync) SetWaitPolicy(policy WaitPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.waitPolicy = policy
+}
+
+// UseCurrentPosition Makes `Write` use and update the file's current position, like write(2),
+// instead of the offset tracked by the writer. Fails if the kernel does not support `IORingFeatRWCurPos`.
+func (r *FileWriterSync) UseCurrentPosition() error {
+	if !r.ring.hasFeature(IORingFeatRWCurPos) {
+		return fmt.Errorf("current position not supported")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.curPosMode = true
+	return nil
+}
+
+// Offset Gets the offset the next `Write` lands at.
+func (r *FileWriterSync) Offset() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.offset
+}
+
+// Write the data to the file and advance the offset by the number of bytes written.
+// Short writes are resubmitted until all of data is written, so an error is returned
+// if and only if fewer than len(data) bytes were written. Implements `io.Writer`.
 func (r *FileWriterSync) Write(data []byte) (int, error) {
-	p := unsafe.Pointer(&data[0])
-	l := C.size_t(len(data))
-	ret := C.write_and_submit_and_wait(r.ring.ring, p, l, C.off_t(r.offset), C.int(r.file.Fd()))
-	if ret >= 0 {
-		return int(ret), nil
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.curPosMode {
+		return r.writeAll(data, currentPosition)
+	}
+
+	n, err := r.writeAll(data, r.offset)
+	r.offset += int64(n)
+	return n, err
+}
+
+// WriteAt Write the data to the file at offset, leaving the writer's offset untouched.
+// Short writes are resubmitted until all of data is written, so an error is returned
+// if and only if fewer than len(data) bytes were written. Implements `io.WriterAt`.
+func (r *FileWriterSync) WriteAt(data []byte, offset int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if offset < 0 {
+		return 0, fmt.Errorf("negative offset %d", offset)
+	}
+
+	return r.writeAll(data, offset)
+}
+
+// Close Close the underlying file. The ring is not destroyed, since it is owned by the caller.
+// Implements `io.Closer`.
+func (r *FileWriterSync) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return os.ErrClosed
+	}
+
+	r.closed = true
+	return r.file.Close()
+}
+
+// Sync Commit the data written so far to stable storage, with an fsync(2) through the ring.
+func (r *FileWriterSync) Sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return os.ErrClosed
+	}
+
+	sqe, err := r.ring.getClaimedSQE(nil)
+	if err != nil {
+		return err
+	}
+	sqe.PrepFsync(int(r.file.Fd()), false)
+
+	_, err = r.run(sqe, "Sync")
+	return err
+}
+
+// writeAll Writes data at offset, resubmitting the rest after a short write.
+func (r *FileWriterSync) writeAll(data []byte, offset int64) (int, error) {
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+
+	written := 0
+	for written < len(data) {
+		n, err := r.write(data[written:], offset)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		if n == 0 {
+			return written, io.ErrShortWrite
+		}
+
+		if offset != currentPosition {
+			offset += int64(n)
+		}
+	}
+
+	return written, nil
+}
+
+// write Submits a single write and waits for its completion.
+func (r *FileWriterSync) write(data []byte, offset int64) (int, error) {
+	sqe, err := r.ring.getClaimedSQE(nil)
+	if err != nil {
+		return 0, err
+	}
+	if err := sqe.PrepWriteV(int(r.file.Fd()), [][]byte{data}, uint64(offset)); err != nil {
+		sqe.Discard()
+		return 0, err
+	}
+
+	return r.run(sqe, "Write")
+}
+
+// run Submits sqe, prepared for op, waits for its completion and gets its result.
+// The SQE keeps the data and iovecs the kernel uses alive until the completion is reaped,
+// so a failed wait leaves nothing behind that the kernel may still use.
+// If the submission fails, the SQE is discarded, unless it already went out, see `SQE.Discard`,
+// in which case the kernel takes it with the wait and its result is reported instead.
+func (r *FileWriterSync) run(sqe *SQE, op string) (int, error) {
+	userData := sqe.UserData()
+
+	if _, err := r.ring.submitSQEs(sqe); err != nil && sqe.Discard() == nil {
+		return 0, err
+	}
+
+	res, err := r.waitPolicy.waitFor(r.ring, userData)
+	if err != nil {
+		return 0, err
+	}
+	if res < 0 {
+		return 0, fmt.Errorf("%s failed %d", op, res)
 	}
 
-	return 0, fmt.Errorf("Write failed %d", ret)
+	return int(res), nil
 }
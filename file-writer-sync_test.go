@@ -1,7 +1,14 @@
 package goliburing
 
 import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
 	"os"
+	"sync"
 	"testing"
 )
 
@@ -62,3 +69,305 @@ func BenchmarkFileWriterSync(b *testing.B) {
 	b.StopTimer()
 	os.Remove(f.Name())
 }
+
+func TestFileWriterSyncConcurrent(t *testing.T) {
+	const writers = 8
+	const writes = 64
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 3*writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			ring, err := NewRing(8, nil)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer ring.Destroy()
+
+			f, err := os.OpenFile(fmt.Sprintf("/tmp/file-writer-sync-concurrent-%d", i), os.O_CREATE|os.O_TRUNC|os.O_APPEND|os.O_WRONLY, 0644)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer os.Remove(f.Name())
+			defer f.Close()
+
+			writer, err := NewFileWriterSync(ring, f)
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			// Two goroutines share each writer.
+			var inner sync.WaitGroup
+			for j := 0; j < 2; j++ {
+				inner.Add(1)
+				go func() {
+					defer inner.Done()
+					data := make([]byte, 128)
+					for k := 0; k < writes; k++ {
+						n, err := writer.Write(data)
+						if err != nil {
+							errs <- err
+							return
+						}
+						if n != len(data) {
+							errs <- fmt.Errorf("Write: want %d, have %d", len(data), n)
+							return
+						}
+					}
+				}()
+			}
+			inner.Wait()
+
+			info, err := f.Stat()
+			if err != nil {
+				errs <- err
+				return
+			}
+			if want, have := int64(2*writes*128), info.Size(); want != have {
+				errs <- fmt.Errorf("file size: want %d, have %d", want, have)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestFileWriterSyncOffset(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	f, err := os.OpenFile("/tmp/file-writer-sync-offset-test", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	writer, err := NewFileWriterSync(ring, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, data := range []string{"hello", ", ", "world"} {
+		if _, err := writer.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want, have := int64(12), writer.Offset(); want != have {
+		t.Fatalf("Offset: want %d, have %d", want, have)
+	}
+
+	if _, err := writer.WriteAt([]byte("W"), 7); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := int64(12), writer.Offset(); want != have {
+		t.Fatalf("Offset after WriteAt: want %d, have %d", want, have)
+	}
+
+	have, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "hello, World"; want != string(have) {
+		t.Fatalf("file: want %q, have %q", want, have)
+	}
+}
+
+func TestFileWriterSyncCurrentPosition(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	f, err := os.OpenFile("/tmp/file-writer-sync-cur-pos-test", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	writer, err := NewFileWriterSync(ring, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.UseCurrentPosition(); err != nil {
+		t.Skip(err)
+	}
+
+	if _, err := f.Write([]byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Write([]byte("def")); err != nil {
+		t.Fatal(err)
+	}
+
+	pos, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := int64(6), pos; want != have {
+		t.Fatalf("file position: want %d, have %d", want, have)
+	}
+
+	have, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "abcdef"; want != string(have) {
+		t.Fatalf("file: want %q, have %q", want, have)
+	}
+}
+
+func TestFileWriterSyncStandardInterfaces(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	f, err := os.OpenFile("/tmp/file-writer-sync-interfaces-test", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	writer, err := NewFileWriterSync(ring, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := bytes.Repeat([]byte("0123456789abcdef"), 1<<14)
+	if _, err := io.Copy(writer, bytes.NewReader(payload)); err != nil {
+		t.Fatal(err)
+	}
+
+	buffered := bufio.NewWriter(writer)
+	logger := log.New(buffered, "", 0)
+	logger.Print("logged")
+	if err := buffered.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := writer.WriteAt([]byte("X"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Write([]byte("closed")); err != os.ErrClosed {
+		t.Fatalf("Write after Close: want %v, have %v", os.ErrClosed, err)
+	}
+
+	have, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append([]byte("X"), payload[1:]...)
+	want = append(want, "logged\n"...)
+	if !bytes.Equal(want, have) {
+		t.Fatalf("file: want %d bytes, have %d bytes", len(want), len(have))
+	}
+}
+
+func TestFileWriterSyncWaitPolicy(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	f, err := os.OpenFile("/tmp/file-writer-sync-wait-policy-test", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	writer, err := NewFileWriterSync(ring, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, 256)
+	for _, policy := range []WaitPolicy{DefaultWaitPolicy, {Spin: 1000}} {
+		writer.SetWaitPolicy(policy)
+		n, err := writer.Write(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, have := len(data), n; want != have {
+			t.Fatalf("Write with %+v: want %d, have %d", policy, want, have)
+		}
+	}
+}
+
+func TestFileWriterSyncSync(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	f, err := os.OpenFile("/tmp/file-writer-sync-sync-test", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	writer, err := NewFileWriterSync(ring, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A completion that is not the writer's, which must be left on the ring.
+	sqe, err := ring.GetEmptySQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqe.PrepRead(-1, make([]byte, 16), 0)
+	sqe.SetData("foreign")
+	if _, err := ring.Submit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := writer.Write([]byte("durable")); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	cqe, err := ring.PeekCQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "foreign", cqe.Data(); want != have {
+		t.Fatalf("Data: want %v, have %v", want, have)
+	}
+	cqe.Seen()
+	if want, have := 0, ring.InFlight(); want != have {
+		t.Fatalf("InFlight: want %d, have %d", want, have)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Sync(); err != os.ErrClosed {
+		t.Fatalf("Sync after Close: want %v, have %v", os.ErrClosed, err)
+	}
+}
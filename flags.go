@@ -1,6 +1,9 @@
 package goliburing
 
 /*
+#define _GNU_SOURCE
+#include <fcntl.h>
+#include <poll.h>
 #include "liburing.h"
 */
 import "C"
@@ -89,6 +92,9 @@ const (
 	// same. Note that this is the default behavior, tasks can still register different personalities through
 	// io_uring_register(2) with IORING_REGISTER_PERSONALITY and specify the personality to use in the sqe.
 	IORingFeatCurPersonality = C.IORING_FEAT_CUR_PERSONALITY
+	// IORingFeatCQESkip If this flag is set, IOSQE_CQE_SKIP_SUCCESS is supported, which suppresses the completion
+	// of an SQE that succeeds.
+	IORingFeatCQESkip = C.IORING_FEAT_CQE_SKIP
 )
 
 // OpFlag operation flag.
@@ -104,7 +110,7 @@ const (
 	// IORingOpFSync File sync.  See also fsync(2). Note that, while I/O is initiated in the order in which it appears in the submission queue, completions are unordered.
 	// For example, an application which places a write I/O followed by an fsync in the submission queue cannot expect the fsync to apply to the write. The two
 	// operations execute in parallel, so the fsync may complete before the write is issued to the storage. The same is also true for previously issued writes
-	// that have not completed prior to the fsync.
+	// that have not completed prior to the fsync. Link the fsync to the write to order them, see `SQE.SetLink` and `Chain`.
 	IORingOpFSync = C.IORING_OP_FSYNC
 	// IORingOpReadFixed Read from pre-mapped buffers. See io_uring_register(2) for details on how to setup a context for fixed reads.
 	IORingOpReadFixed = C.IORING_OP_READ_FIXED
@@ -199,3 +205,109 @@ const (
 	// IORingOpEPollCtl todo
 	IORingOpEPollCtl = C.IORING_OP_EPOLL_CTL
 )
+
+// CQEFlag Completion queue entry flags.
+type CQEFlag = uint32
+
+const (
+	// IORingCQEFBuffer If set, the upper 16 bits of the flags are the buffer ID.
+	IORingCQEFBuffer = C.IORING_CQE_F_BUFFER
+	// IORingCQEFMore If set, the parent SQE will generate more CQE entries.
+	IORingCQEFMore = C.IORING_CQE_F_MORE
+)
+
+// SQEFlag Submission queue entry flags.
+// Since preparing an SQE resets its flags, flags must be set after calling a `Prep` method.
+type SQEFlag = uint8
+
+const (
+	// IOSQEFixedFile When this flag is specified, fd is an index into the files array registered with
+	// the io_uring instance (see the IORING_REGISTER_FILES section of the io_uring_register(2) man page).
+	IOSQEFixedFile SQEFlag = C.IOSQE_FIXED_FILE
+	// IOSQEIOLink When this flag is specified, the SQE forms a link with the next SQE in the submission ring.
+	// That next SQE will not be started before this one completes successfully. If this one fails or
+	// completes short, the rest of the chain completes with -ECANCELED.
+	IOSQEIOLink SQEFlag = C.IOSQE_IO_LINK
+	// IOSQEIOHardLink Like IOSQEIOLink, but the link is not severed when this SQE fails or completes short,
+	// so the next SQE runs regardless of the result of this one.
+	IOSQEIOHardLink SQEFlag = C.IOSQE_IO_HARDLINK
+	// IOSQEIODrain When this flag is specified, the SQE will not be started before previously submitted SQEs
+	// have completed, and new SQEs will not be started before this one completes.
+	IOSQEIODrain SQEFlag = C.IOSQE_IO_DRAIN
+	// IOSQEAsync Normal operation for io_uring is to try and issue an SQE as non-blocking first, and if that fails,
+	// execute it in an async manner. This flag skips the non-blocking attempt and always punts the SQE to io-wq,
+	// which saves the attempt for operations that are known to block.
+	IOSQEAsync SQEFlag = C.IOSQE_ASYNC
+	// IOSQEBufferSelect Used in conjunction with IORING_OP_PROVIDE_BUFFERS. If set, the operation picks a buffer
+	// from the group set in buf_group when it is ready to transfer data, and the buffer ID is passed back
+	// in the upper 16 bits of the CQE flags. See `IORingCQEFBuffer`.
+	IOSQEBufferSelect SQEFlag = C.IOSQE_BUFFER_SELECT
+	// IOSQECQESkipSuccess When this flag is specified, no completion is posted if the SQE succeeds.
+	// A completion is still posted if it fails. Requires `IORingFeatCQESkip`.
+	IOSQECQESkipSuccess SQEFlag = C.IOSQE_CQE_SKIP_SUCCESS
+)
+
+// SyncFileRangeFlag Flags for `SQE.PrepSyncFileRange`, see sync_file_range(2).
+type SyncFileRangeFlag = uint32
+
+const (
+	// SyncFileRangeWaitBefore Wait upon write-out of all pages in the range that have already been submitted
+	// to the device driver for write-out before performing any write.
+	SyncFileRangeWaitBefore SyncFileRangeFlag = C.SYNC_FILE_RANGE_WAIT_BEFORE
+	// SyncFileRangeWrite Initiate write-out of all dirty pages in the range which are not presently submitted for write-out.
+	SyncFileRangeWrite SyncFileRangeFlag = C.SYNC_FILE_RANGE_WRITE
+	// SyncFileRangeWaitAfter Wait upon write-out of all pages in the range after performing any write.
+	SyncFileRangeWaitAfter SyncFileRangeFlag = C.SYNC_FILE_RANGE_WAIT_AFTER
+)
+
+// PollEvent Events for `SQE.PrepPollAdd`, as for poll(2).
+type PollEvent = uint32
+
+const (
+	// PollIn There is data to read.
+	PollIn PollEvent = C.POLLIN
+	// PollPri There is some exceptional condition on the fd, such as out-of-band data on a TCP socket.
+	PollPri PollEvent = C.POLLPRI
+	// PollOut Writing is now possible.
+	PollOut PollEvent = C.POLLOUT
+	// PollErr Error condition. Always reported, it does not need to be requested.
+	PollErr PollEvent = C.POLLERR
+	// PollHup Hang up, such as the other end of a pipe being closed. Always reported, it does not need to be requested.
+	PollHup PollEvent = C.POLLHUP
+	// PollNVal Invalid request, the fd is not open. Always reported, it does not need to be requested.
+	PollNVal PollEvent = C.POLLNVAL
+)
+
+// TimeoutFlag Flags for `SQE.PrepTimeout` and `SQE.PrepTimeoutUpdate`.
+// Timeouts are relative and measured on CLOCK_MONOTONIC unless flags say otherwise.
+type TimeoutFlag = uint32
+
+const (
+	// IORingTimeoutAbs The timeout is an absolute time on its clock rather than relative to now.
+	IORingTimeoutAbs TimeoutFlag = C.IORING_TIMEOUT_ABS
+	// IORingTimeoutBoottime Measure the timeout on CLOCK_BOOTTIME, which keeps counting while the system is suspended.
+	IORingTimeoutBoottime TimeoutFlag = C.IORING_TIMEOUT_BOOTTIME
+	// IORingTimeoutRealtime Measure the timeout on CLOCK_REALTIME, the wall clock.
+	IORingTimeoutRealtime TimeoutFlag = C.IORING_TIMEOUT_REALTIME
+	// IORingTimeoutETimeSuccess Make an expired timeout count as a success, so it does not break a link.
+	// It still completes with -ETIME.
+	IORingTimeoutETimeSuccess TimeoutFlag = C.IORING_TIMEOUT_ETIME_SUCCESS
+	// IORingTimeoutMultishot Rearm the timeout every time it expires. Each expiration posts a completion,
+	// and the count of `SQE.PrepTimeout` is the number of expirations, or 0 for no limit. Available since 6.4.
+	IORingTimeoutMultishot TimeoutFlag = C.IORING_TIMEOUT_MULTISHOT
+)
+
+// CancelFlag Flags for `SQE.PrepCancel`, `SQE.PrepCancelFd` and `Ring.Cancel`.
+type CancelFlag = uint32
+
+const (
+	// IORingAsyncCancelAll Cancel every request that matches, instead of only the first one.
+	// The cancellation then completes with the number of requests canceled.
+	IORingAsyncCancelAll CancelFlag = C.IORING_ASYNC_CANCEL_ALL
+	// IORingAsyncCancelFd Match requests on the fd, rather than by user data.
+	IORingAsyncCancelFd CancelFlag = C.IORING_ASYNC_CANCEL_FD
+	// IORingAsyncCancelAny Match any request in flight, whatever its user data or fd.
+	IORingAsyncCancelAny CancelFlag = C.IORING_ASYNC_CANCEL_ANY
+	// IORingAsyncCancelFdFixed The fd to match is a slot of the fixed file table, see `Ring.RegisterFiles`.
+	IORingAsyncCancelFdFixed CancelFlag = C.IORING_ASYNC_CANCEL_FD_FIXED
+)
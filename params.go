@@ -30,6 +30,7 @@ struct io_uring_params_create_result create_params(unsigned flags, unsigned sq_t
 	res.params->sq_thread_cpu = sq_thread_cpu;
 	res.params->sq_thread_idle = sq_thread_idle;
 	res.params->cq_entries = cq_entries;
+	res.ret = 0;
 	return res;
 }
 
@@ -0,0 +1,54 @@
+package goliburing
+
+/*
+#include <string.h>
+#include <errno.h>
+*/
+import "C"
+
+import "fmt"
+
+// ErrRegisterCode Error codes returned when registering resources with a Ring.
+type ErrRegisterCode = int
+
+const (
+	// ErrRegisterEMALLOC Could not allocate memory for the resources.
+	ErrRegisterEMALLOC ErrRegisterCode = 0
+	// ErrRegisterEBUSY Resources of the same kind are already registered.
+	ErrRegisterEBUSY = C.EBUSY
+	// ErrRegisterEFAULT The resources are outside your accessible address space.
+	ErrRegisterEFAULT = C.EFAULT
+	// ErrRegisterEINVAL Too many or too few resources were given, or a resource is too large.
+	ErrRegisterEINVAL = C.EINVAL
+	// ErrRegisterENOMEM Insufficient kernel resources are available, or the locked memory limit
+	// (RLIMIT_MEMLOCK) was reached.
+	ErrRegisterENOMEM = C.ENOMEM
+	// ErrRegisterENXIO Nothing is registered, so there is nothing to unregister or update.
+	ErrRegisterENXIO = C.ENXIO
+)
+
+// ErrRegister Represents an error while registering resources with a Ring.
+type ErrRegister struct {
+	Code    ErrRegisterCode
+	Message string
+}
+
+// NewErrRegister Creates a new register error.
+// A code of 1 indicates a malloc failure, a negative code is a negated errno.
+func NewErrRegister(code C.int) *ErrRegister {
+	if code == 1 {
+		return &ErrRegister{
+			Code:    ErrRegisterEMALLOC,
+			Message: "could not allocate memory",
+		}
+	}
+
+	return &ErrRegister{
+		Code:    ErrRegisterCode(-code),
+		Message: fmt.Sprintf("code: %d, err: %s", -code, C.GoString(C.strerror(-code))),
+	}
+}
+
+func (e *ErrRegister) Error() string {
+	return fmt.Sprintf("register failed: %s", e.Message)
+}
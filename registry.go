@@ -0,0 +1,57 @@
+package goliburing
+
+import "sync"
+
+// registry Maps user data tokens to the SQEs that are in flight.
+// The token is handed to the kernel as user data instead of a pointer to the SQE.
+// Go buffers are still handed over by address, through the SQE and its iovecs, so each SQE
+// pins the buffers it references, see `SQE.pin`, until its completion is reaped.
+// Tokens start at 1, a user data of 0 never resolves to an SQE.
+type registry struct {
+	mu     sync.Mutex
+	next   uint64
+	values map[uint64]*SQE
+}
+
+func newRegistry() *registry {
+	return &registry{
+		values: make(map[uint64]*SQE),
+	}
+}
+
+// register Stores sqe and returns the token that resolves to it.
+func (r *registry) register(sqe *SQE) uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.next++
+	r.values[r.next] = sqe
+	return r.next
+}
+
+// lookup Gets the SQE stored for token, keeping it stored.
+func (r *registry) lookup(token uint64) (*SQE, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sqe, ok := r.values[token]
+	return sqe, ok
+}
+
+// release Forgets the SQE stored for token and returns it.
+func (r *registry) release(token uint64) (*SQE, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sqe, ok := r.values[token]
+	delete(r.values, token)
+	return sqe, ok
+}
+
+// len Gets the number of SQEs currently stored.
+func (r *registry) len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.values)
+}
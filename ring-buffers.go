@@ -0,0 +1,123 @@
+package goliburing
+
+/*
+#include <stdlib.h>
+#include <string.h>
+#include <unistd.h>
+#include <sys/uio.h>
+#include "liburing.h"
+
+// Frees the first nr buffers and the iovecs describing them.
+void free_buffers(struct iovec *iov, unsigned nr) {
+	for (unsigned i = 0; i < nr; i++) {
+		free(iov[i].iov_base);
+	}
+	free(iov);
+}
+
+// Represents the result of registering buffers.
+// Ret values:
+//  = 0  	success
+//  = 1 	indicates a malloc failure.
+//  < 0 	failure from io_uring_register_buffers.
+struct register_buffers_result {
+	int ret;
+	struct iovec *iov;
+};
+
+// Allocates nr zeroed, page aligned buffers of size bytes and registers them with the ring.
+struct register_buffers_result register_buffers(struct io_uring *ring, unsigned nr, size_t size) {
+	struct register_buffers_result res;
+	res.iov = calloc(nr, sizeof(*res.iov));
+	if (!res.iov) {
+		res.ret = 1;
+		return res;
+	}
+
+	size_t page = sysconf(_SC_PAGESIZE);
+	size_t alloc = (size + page - 1) / page * page;
+	for (unsigned i = 0; i < nr; i++) {
+		if (posix_memalign(&res.iov[i].iov_base, page, alloc) != 0) {
+			free_buffers(res.iov, i);
+			res.ret = 1;
+			return res;
+		}
+		memset(res.iov[i].iov_base, 0, alloc);
+		res.iov[i].iov_len = size;
+	}
+
+	res.ret = io_uring_register_buffers(ring, res.iov, nr);
+	if (res.ret < 0) {
+		free_buffers(res.iov, nr);
+	}
+
+	return res;
+}
+*/
+import "C"
+import "unsafe"
+
+// RegisterBuffers Allocates count page aligned buffers of size bytes, owned by the ring,
+// and registers them with the kernel for use with `SQE.PrepReadFixed` and `SQE.PrepWriteFixed`.
+// The kernel pins registered buffers once, instead of on every I/O.
+// Error will be nil on success and of type `ErrRegister` on failure.
+func (r *Ring) RegisterBuffers(count int, size int) error {
+	if r.buffers != nil {
+		return NewErrRegister(-C.EBUSY)
+	}
+
+	res := C.register_buffers(r.ring, C.uint(count), C.size_t(size))
+	if res.ret != 0 {
+		return NewErrRegister(res.ret)
+	}
+
+	r.buffers = res.iov
+	r.bufferCount = count
+	return nil
+}
+
+// UnregisterBuffers Unregisters and frees the buffers registered with `RegisterBuffers`.
+// Slices returned by `Buffer` must not be used afterwards.
+// Error will be nil on success and of type `ErrRegister` on failure.
+func (r *Ring) UnregisterBuffers() error {
+	if r.buffers == nil {
+		return NewErrRegister(-C.ENXIO)
+	}
+
+	ret := C.io_uring_unregister_buffers(r.ring)
+	if ret < 0 {
+		return NewErrRegister(ret)
+	}
+
+	r.freeBuffers()
+	return nil
+}
+
+// BufferCount Gets the number of registered buffers.
+func (r *Ring) BufferCount() int {
+	return r.bufferCount
+}
+
+// Buffer Gets the registered buffer at index.
+// The memory is owned by the ring and stays valid until the buffers are unregistered
+// or the ring is destroyed. Panics if index is out of range.
+func (r *Ring) Buffer(index int) []byte {
+	iov := r.bufferIOV(index)
+	return (*[1 << 30]byte)(iov.iov_base)[:iov.iov_len:iov.iov_len]
+}
+
+func (r *Ring) bufferIOV(index int) *C.struct_iovec {
+	if index < 0 || index >= r.bufferCount {
+		panic("goliburing: registered buffer index out of range")
+	}
+
+	return (*C.struct_iovec)(unsafe.Pointer(uintptr(unsafe.Pointer(r.buffers)) + uintptr(index)*C.sizeof_struct_iovec))
+}
+
+func (r *Ring) freeBuffers() {
+	if r.buffers != nil {
+		C.free_buffers(r.buffers, C.uint(r.bufferCount))
+		r.buffers = nil
+		r.bufferCount = 0
+	}
+}
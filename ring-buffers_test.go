@@ -0,0 +1,104 @@
+package goliburing
+
+import (
+	"os"
+	"testing"
+	"unsafe"
+)
+
+func TestRegisterBuffers(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	if err := ring.RegisterBuffers(2, 4096); err != nil {
+		t.Fatal(err)
+	}
+
+	if want, have := 2, ring.BufferCount(); want != have {
+		t.Fatalf("BufferCount: want %d, have %d", want, have)
+	}
+	for i := 0; i < ring.BufferCount(); i++ {
+		buf := ring.Buffer(i)
+		if want, have := 4096, len(buf); want != have {
+			t.Fatalf("Buffer(%d): want len %d, have %d", i, want, have)
+		}
+		if addr := uintptr(unsafe.Pointer(&buf[0])); addr%uintptr(os.Getpagesize()) != 0 {
+			t.Fatalf("Buffer(%d): %#x is not page aligned", i, addr)
+		}
+	}
+
+	err = ring.RegisterBuffers(1, 4096)
+	if e, ok := err.(*ErrRegister); !ok || e.Code != ErrRegisterEBUSY {
+		t.Fatalf("RegisterBuffers: want ErrRegisterEBUSY, have %v", err)
+	}
+
+	if err := ring.UnregisterBuffers(); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 0, ring.BufferCount(); want != have {
+		t.Fatalf("BufferCount: want %d, have %d", want, have)
+	}
+}
+
+func TestPrepWriteFixedReadFixed(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	if err := ring.RegisterBuffers(2, 4096); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile("tmp-prep-fixed", os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	want := "fixed buffers"
+	n := copy(ring.Buffer(0), want)
+
+	sqe, err := ring.GetEmptySQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqe.PrepWriteFixed(int(f.Fd()), 0, n, 0)
+	ring.Submit()
+	cqe, err := ring.WaitCQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, err := cqe.Result()
+	cqe.Seen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := n, written; want != have {
+		t.Fatalf("PrepWriteFixed: want %d, have %d", want, have)
+	}
+
+	sqe, err = ring.GetEmptySQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqe.PrepReadFixed(int(f.Fd()), 1, len(ring.Buffer(1)), 0)
+	ring.Submit()
+	cqe, err = ring.WaitCQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := cqe.Result()
+	cqe.Seen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := string(ring.Buffer(1)[:read]); want != have {
+		t.Fatalf("PrepReadFixed: want %q, have %q", want, have)
+	}
+}
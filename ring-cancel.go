@@ -0,0 +1,52 @@
+package goliburing
+
+import (
+	"syscall"
+)
+
+// Cancel Cancel requests in flight and wait for the cancellation to complete.
+// Requests are matched by userData, or by fd if flags has `IORingAsyncCancelFd`, or all of them if
+// flags has `IORingAsyncCancelAny`. Only the first match is canceled unless flags has `IORingAsyncCancelAll`.
+// Returns the number of requests canceled. The canceled requests still post their completions, with ECANCELED.
+// The completion of the cancellation is reaped by Cancel alone, and the other completions reaped while
+// waiting for it are kept for the next `WaitCQE`, `PeekCQE` or `PeekBatchCQE`, so none are lost.
+// Error will be of type `ErrGetSQE`, `ErrSubmit` or `ErrWaitCQE`, or a `syscall.Errno` if the cancellation failed,
+// such as syscall.EALREADY if the request was already running and could not be canceled, in which case
+// it completes on its own. If the submission fails, the cancellation is discarded, unless it already went out,
+// see `SQE.Discard`, in which case the kernel takes it with the wait and its result is reported instead.
+func (r *Ring) Cancel(userData uint64, fd int, flags CancelFlag) (int, error) {
+	sqe, err := r.getClaimedSQE(nil)
+	if err != nil {
+		return 0, err
+	}
+	if flags&IORingAsyncCancelFd != 0 {
+		sqe.PrepCancelFd(fd, flags)
+	} else {
+		sqe.PrepCancel(userData, flags)
+	}
+	token := sqe.UserData()
+
+	if _, err := r.submitSQEs(sqe); err != nil && sqe.Discard() == nil {
+		return 0, err
+	}
+
+	cqe, err := r.reap(func(s *SQE) bool {
+		return s != nil && s.token == token
+	}, -1)
+	if err != nil {
+		return 0, err
+	}
+	res := cqe.Res()
+	cqe.Seen()
+
+	switch {
+	case res >= 0 && flags&IORingAsyncCancelAll != 0:
+		return int(res), nil
+	case res >= 0:
+		return 1, nil
+	case res == -int32(syscall.ENOENT):
+		return 0, nil
+	default:
+		return 0, syscall.Errno(-res)
+	}
+}
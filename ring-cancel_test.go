@@ -0,0 +1,189 @@
+package goliburing
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// submitPipeReads Submits count reads of the empty pipe r, which stay in flight until canceled.
+func submitPipeReads(t *testing.T, ring *Ring, r *os.File, count int) []uint64 {
+	userData := make([]uint64, count)
+	for i := range userData {
+		sqe, err := ring.GetEmptySQE()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sqe.PrepRead(int(r.Fd()), make([]byte, 16), 0)
+		userData[i] = sqe.SetData(i)
+	}
+	if _, err := ring.Submit(); err != nil {
+		t.Fatal(err)
+	}
+
+	return userData
+}
+
+// waitCanceled Waits for count completions and checks that they were canceled.
+func waitCanceled(t *testing.T, ring *Ring, count int) {
+	for i := 0; i < count; i++ {
+		cqe, err := ring.WaitCQE()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cqe.Canceled() {
+			t.Fatalf("completion %d: want canceled, have %v", i, cqe.Errno())
+		}
+		if _, ok := cqe.Data().(int); !ok {
+			t.Fatalf("completion %d: want data, have %v", i, cqe.Data())
+		}
+		cqe.Seen()
+	}
+}
+
+func TestRingCancel(t *testing.T) {
+	ring, err := NewRing(16, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	userData := submitPipeReads(t, ring, r, 3)
+	n, err := ring.Cancel(userData[1], 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 1, n; want != have {
+		t.Fatalf("Cancel by user data: want %d, have %d", want, have)
+	}
+	waitCanceled(t, ring, 1)
+
+	n, err = ring.Cancel(0, int(r.Fd()), IORingAsyncCancelFd|IORingAsyncCancelAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 2, n; want != have {
+		t.Fatalf("Cancel by fd: want %d, have %d", want, have)
+	}
+	waitCanceled(t, ring, 2)
+
+	submitPipeReads(t, ring, r, 4)
+	n, err = ring.Cancel(0, 0, IORingAsyncCancelAny|IORingAsyncCancelAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 4, n; want != have {
+		t.Fatalf("Cancel any: want %d, have %d", want, have)
+	}
+	waitCanceled(t, ring, 4)
+
+	n, err = ring.Cancel(userData[0], 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 0, n; want != have {
+		t.Fatalf("Cancel of a completed request: want %d, have %d", want, have)
+	}
+	if want, have := 0, ring.InFlight(); want != have {
+		t.Fatalf("InFlight: want %d, have %d", want, have)
+	}
+}
+
+func TestRingCancelBacklog(t *testing.T) {
+	ring, err := NewRing(16, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	// The completions reaped by Cancel come out of PeekBatchCQE before the ones still queued.
+	submitPipeReads(t, ring, r, 3)
+	if _, err := ring.Cancel(0, int(r.Fd()), IORingAsyncCancelFd|IORingAsyncCancelAll); err != nil {
+		t.Fatal(err)
+	}
+
+	cqes := make([]CQE, 8)
+	n := ring.PeekBatchCQE(cqes)
+	if want, have := 3, n; want != have {
+		t.Fatalf("PeekBatchCQE: want %d, have %d", want, have)
+	}
+	for i := 0; i < n; i++ {
+		if !cqes[i].Canceled() {
+			t.Fatalf("completion %d: want canceled, have %v", i, cqes[i].Errno())
+		}
+	}
+	ring.CQAdvance(uint32(n))
+
+	if _, err := ring.PeekCQE(); err == nil {
+		t.Fatal("PeekCQE: want no completion after CQAdvance")
+	}
+}
+
+func TestRingCancelConcurrentWait(t *testing.T) {
+	ring, err := NewRing(16, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	// A goroutine blocked in WaitCQE gets the canceled reads, but never the completion of the cancellation.
+	const count = 3
+	submitPipeReads(t, ring, r, count)
+	waited := make(chan error, 1)
+	go func() {
+		for i := 0; i < count; i++ {
+			cqe, err := ring.WaitCQE()
+			if err != nil {
+				waited <- err
+				return
+			}
+			cqe.Seen()
+		}
+		waited <- nil
+	}()
+
+	canceled := make(chan int, 1)
+	go func() {
+		n, err := ring.Cancel(0, int(r.Fd()), IORingAsyncCancelFd|IORingAsyncCancelAll)
+		if err != nil {
+			t.Error(err)
+		}
+		canceled <- n
+	}()
+
+	select {
+	case n := <-canceled:
+		if want, have := count, n; want != have {
+			t.Fatalf("Cancel: want %d, have %d", want, have)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Cancel: still waiting for its completion")
+	}
+	if err := <-waited; err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 0, ring.InFlight(); want != have {
+		t.Fatalf("InFlight: want %d, have %d", want, have)
+	}
+}
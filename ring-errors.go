@@ -62,3 +62,43 @@ func NewErrCreateRing(code C.int, subErr error) *ErrCreateRing {
 func (e *ErrCreateRing) Error() string {
 	return fmt.Sprintf("create ring failed: %s", e.Message)
 }
+
+// ErrSubmitCode Error codes returned from submitting SQEs.
+type ErrSubmitCode = int
+
+const (
+	// ErrSubmitEAGAIN The kernel was unable to allocate memory for the request, or otherwise ran out
+	// of resources to handle it. The application should wait for some completions and try again.
+	ErrSubmitEAGAIN ErrSubmitCode = C.EAGAIN
+	// ErrSubmitEBUSY The completion queue overflowed and the kernel could not flush the overflown
+	// events to it. The application must reap completions before submitting again.
+	ErrSubmitEBUSY = C.EBUSY
+	// ErrSubmitEBADR Completion events were dropped due to the completion queue overflowing
+	// on a kernel without IORING_FEAT_NODROP.
+	ErrSubmitEBADR = C.EBADR
+	// ErrSubmitETIME The timeout expired before enough completions were available.
+	ErrSubmitETIME = C.ETIME
+)
+
+// ErrSubmit Represents an error while submitting SQEs.
+type ErrSubmit struct {
+	Code    ErrSubmitCode
+	Message string
+}
+
+// NewErrSubmit Creates a new submit error from a negated errno.
+func NewErrSubmit(code C.int) *ErrSubmit {
+	return &ErrSubmit{
+		Code:    ErrSubmitCode(-code),
+		Message: fmt.Sprintf("code: %d, err: %s", -code, C.GoString(C.strerror(-code))),
+	}
+}
+
+func (e *ErrSubmit) Error() string {
+	return fmt.Sprintf("submit failed: %s", e.Message)
+}
+
+// Temporary Reports whether the submission can be retried once completions have been reaped.
+func (e *ErrSubmit) Temporary() bool {
+	return e.Code == ErrSubmitEAGAIN || e.Code == ErrSubmitEBUSY
+}
@@ -0,0 +1,72 @@
+package goliburing
+
+/*
+#include "liburing.h"
+*/
+import "C"
+
+// RegisterFiles Registers fds as the ring's fixed file table, fds[i] is placed in slot i.
+// A fd of -1 leaves the slot empty. Use `SQE.SetFixedFile` to target a slot instead of a fd.
+// Error will be nil on success and of type `ErrRegister` on failure.
+func (r *Ring) RegisterFiles(fds []int) error {
+	if len(fds) == 0 {
+		return NewErrRegister(-C.EINVAL)
+	}
+
+	cfds := toCInts(fds)
+	ret := C.io_uring_register_files(r.ring, &cfds[0], C.uint(len(cfds)))
+	if ret < 0 {
+		return NewErrRegister(ret)
+	}
+
+	return nil
+}
+
+// RegisterFilesSparse Registers a fixed file table of count empty slots,
+// to be filled in later with `UpdateFiles`.
+// Error will be nil on success and of type `ErrRegister` on failure.
+func (r *Ring) RegisterFilesSparse(count uint32) error {
+	ret := C.io_uring_register_files_sparse(r.ring, C.uint(count))
+	if ret < 0 {
+		return NewErrRegister(ret)
+	}
+
+	return nil
+}
+
+// UpdateFiles Replaces the slots of the fixed file table starting at offset with fds.
+// A fd of -1 clears the slot. Returns the number of slots updated.
+// Error will be nil on success and of type `ErrRegister` on failure.
+func (r *Ring) UpdateFiles(offset uint32, fds []int) (int, error) {
+	if len(fds) == 0 {
+		return 0, nil
+	}
+
+	cfds := toCInts(fds)
+	ret := C.io_uring_register_files_update(r.ring, C.uint(offset), &cfds[0], C.uint(len(cfds)))
+	if ret < 0 {
+		return 0, NewErrRegister(ret)
+	}
+
+	return int(ret), nil
+}
+
+// UnregisterFiles Unregisters the fixed file table.
+// Error will be nil on success and of type `ErrRegister` on failure.
+func (r *Ring) UnregisterFiles() error {
+	ret := C.io_uring_unregister_files(r.ring)
+	if ret < 0 {
+		return NewErrRegister(ret)
+	}
+
+	return nil
+}
+
+func toCInts(values []int) []C.int {
+	cvalues := make([]C.int, len(values))
+	for i, v := range values {
+		cvalues[i] = C.int(v)
+	}
+
+	return cvalues
+}
@@ -0,0 +1,112 @@
+package goliburing
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestRegisterFiles(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	f, err := os.OpenFile("tmp-register-files", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := ring.RegisterFiles([]int{-1, int(f.Fd())}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "fixed file"
+	sqe, err := ring.GetEmptySQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sqe.PrepWriteV(1, [][]byte{[]byte(want)}, 0); err != nil {
+		t.Fatal(err)
+	}
+	sqe.SetFixedFile()
+	ring.Submit()
+	cqe, err := ring.WaitCQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cqe.Result()
+	cqe.Seen()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	have, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want != string(have) {
+		t.Fatalf("fixed file write: want %q, have %q", want, have)
+	}
+
+	if err := ring.UnregisterFiles(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegisterFilesSparse(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	f, err := os.OpenFile("tmp-register-files-sparse", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := ring.RegisterFilesSparse(4); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := ring.UpdateFiles(2, []int{int(f.Fd())})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 1, updated; want != have {
+		t.Fatalf("UpdateFiles: want %d, have %d", want, have)
+	}
+
+	sqe, err := ring.GetEmptySQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sqe.PrepWriteV(0, [][]byte{[]byte("empty")}, 0); err != nil {
+		t.Fatal(err)
+	}
+	sqe.SetFixedFile()
+	ring.Submit()
+	cqe, err := ring.WaitCQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	errno := cqe.Errno()
+	cqe.Seen()
+	if errno == 0 {
+		t.Fatal("write to empty fixed file slot: want error, have none")
+	}
+
+	if err := ring.UnregisterFiles(); err != nil {
+		t.Fatal(err)
+	}
+	_, err = ring.UpdateFiles(0, []int{int(f.Fd())})
+	if e, ok := err.(*ErrRegister); !ok || e.Code != ErrRegisterENXIO {
+		t.Fatalf("UpdateFiles: want ErrRegisterENXIO, have %v", err)
+	}
+}
@@ -3,8 +3,13 @@ package goliburing
 /*
 #cgo pkg-config: liburing
 
+#include <errno.h>
+#include <signal.h>
+#include <stdint.h>
 #include <stdlib.h>
 #include <string.h>
+#include <unistd.h>
+#include <sys/syscall.h>
 #include <sys/time.h>
 #include "liburing.h"
 
@@ -44,30 +49,107 @@ void destroy_ring(struct io_uring* ring) {
 	}
 }
 
-// static void msec_to_ts(struct __kernel_timespec *ts, unsigned int msec)
-// {
-// 	ts->tv_sec = msec / 1000;
-// 	ts->tv_nsec = (msec % 1000) * 1000000;
-// }
+static void nsec_to_ts(struct __kernel_timespec *ts, long long nsec) {
+	ts->tv_sec = nsec / 1000000000;
+	ts->tv_nsec = nsec % 1000000000;
+}
+
+// Copies a prepared sqe into the next free entry of the submission queue.
+// Returns 0 if the queue is full.
+int queue_sqe(struct io_uring *ring, const struct io_uring_sqe *sqe) {
+	struct io_uring_sqe *entry = io_uring_get_sqe(ring);
+	if (!entry) {
+		return 0;
+	}
 
-// int wait_cqes(struct io_uring *ring, struct io_uring_cqe **cqe_ptr, unsigned wait_nr) {
-// 	struct __kernel_timespec ts;
-// 	msec_to_ts(&ts, 1000);
+	*entry = *sqe;
+	return 1;
+}
 
-// 	return io_uring_wait_cqes(ring, cqe_ptr, wait_nr, &ts, 0);
-// }
+// Gets the number of entries published to the kernel that it has not consumed yet.
+unsigned sq_unconsumed(struct io_uring *ring) {
+	return __atomic_load_n(ring->sq.ktail, __ATOMIC_ACQUIRE) - __atomic_load_n(ring->sq.khead, __ATOMIC_ACQUIRE);
+}
+
+// Publishes the queued entries to the kernel, like liburing does when it submits.
+void publish_sq(struct io_uring *ring) {
+	struct io_uring_sq *sq = &ring->sq;
+	if (sq->sqe_head != sq->sqe_tail) {
+		sq->sqe_head = sq->sqe_tail;
+		__atomic_store_n(sq->ktail, sq->sqe_tail, __ATOMIC_RELEASE);
+	}
+}
+
+// Enters the kernel to submit to_submit published entries and wait for wait_nr completions,
+// for up to nsec nanoseconds unless nsec is negative. Unlike liburing's submit and wait functions,
+// it never publishes entries itself, which is left to publish_sq under the ring's SQE lock.
+// Returns the number of entries submitted, or -errno, with -ETIME if the wait timed out.
+int ring_enter(struct io_uring *ring, unsigned to_submit, unsigned wait_nr, long long nsec) {
+	unsigned flags = 0;
+	if (ring->flags & IORING_SETUP_SQPOLL) {
+		// The poll thread submits on its own, unless it went to sleep.
+		__atomic_thread_fence(__ATOMIC_SEQ_CST);
+		if (__atomic_load_n(ring->sq.kflags, __ATOMIC_RELAXED) & IORING_SQ_NEED_WAKEUP) {
+			flags |= IORING_ENTER_SQ_WAKEUP;
+		} else if (!wait_nr) {
+			return to_submit;
+		}
+	} else if (!to_submit && !wait_nr) {
+		return 0;
+	}
+
+	struct __kernel_timespec ts;
+	struct io_uring_getevents_arg arg;
+	void *argp = NULL;
+	size_t argsz = _NSIG / 8;
+	if (wait_nr) {
+		flags |= IORING_ENTER_GETEVENTS;
+		if (nsec >= 0) {
+			nsec_to_ts(&ts, nsec);
+			memset(&arg, 0, sizeof(arg));
+			arg.sigmask_sz = _NSIG / 8;
+			arg.ts = (unsigned long long)(uintptr_t)&ts;
+			flags |= IORING_ENTER_EXT_ARG;
+			argp = &arg;
+			argsz = sizeof(arg);
+		}
+	}
+
+	int ret = syscall(__NR_io_uring_enter, ring->ring_fd, to_submit, wait_nr, flags, argp, argsz);
+	return ret < 0 ? -errno : ret;
+}
 */
 import "C"
-import "fmt"
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// waitCQEContextInterval The longest time `WaitCQEContext` blocks in the kernel
+// before checking whether its context is done.
+const waitCQEContextInterval = 10 * time.Millisecond
 
 // Ring Wrapper around io_uring.
 type Ring struct {
 	ring       *C.struct_io_uring
 	Params     *Params
 	queueDepth uint32
-	sqes       []*SQE
-	sqeIndex   uint32
-	cqe        *CQE
+	sqeMu      sync.Mutex
+	allSQEs    []*SQE
+	freeSQEs   []*SQE
+	// pending SQEs handed out and not submitted yet, in submission queue order.
+	pending []*SQE
+	sqes    *registry
+	cqMu    sync.Mutex
+	// backlog Completions taken out of the completion queue that wait for their reaper, oldest first.
+	backlog []C.struct_io_uring_cqe
+	// reaping Set while a goroutine blocks in the kernel to wait for completions on behalf of every reaper.
+	reaping bool
+	// reaped Closed and replaced when the backlog grows or reaping stops, to wake the other reapers.
+	reaped      chan struct{}
+	buffers     *C.struct_iovec
+	bufferCount int
 }
 
 // NewRing Create a new Ring.
@@ -96,13 +178,20 @@ func NewRing(queueDepth uint32, params *Params) (*Ring, error) {
 		ring:       res.ring,
 		Params:     params,
 		queueDepth: queueDepth,
-		sqes:       make([]*SQE, queueDepth),
-		sqeIndex:   0,
+		freeSQEs:   make([]*SQE, 0, queueDepth),
+		sqes:       newRegistry(),
+		reaped:     make(chan struct{}),
 	}
-	ring.cqe = newCQE(ring)
 
+	// Pre-allocate an SQE for every slot, more are allocated
+	// if completions are not reaped as fast as SQEs are handed out.
 	for i := 0; i < int(queueDepth); i++ {
-		ring.sqes[i], _ = newSQE(ring)
+		sqe, err := ring.newSQE()
+		if err != nil {
+			ring.Destroy()
+			return nil, NewErrCreateRing(1, nil)
+		}
+		ring.freeSQEs = append(ring.freeSQEs, sqe)
 	}
 
 	return ring, nil
@@ -113,34 +202,610 @@ func (r *Ring) QueueDepth() uint32 {
 	return r.queueDepth
 }
 
+// hasFeature Reports whether the kernel set f in the ring's params.
+func (r *Ring) hasFeature(f FeatureFlag) bool {
+	return r.Params.Features()&f != 0
+}
+
 // Destroy Destroy the ring.
 func (r *Ring) Destroy() {
 	C.destroy_ring(r.ring)
+	r.freeBuffers()
+	for _, sqe := range r.allSQEs {
+		sqe.unpin()
+		sqe.destroy()
+	}
+	r.allSQEs = nil
+	r.freeSQEs = nil
+	r.pending = nil
+	r.backlog = nil
 	r.Params.Destroy()
 }
 
 // GetEmptySQE Gets an empty submission queue entry.
+// The SQE is prepared in memory of its own, and goes out with the next `Submit`, `SubmitAndWait` or
+// `SubmitAndWaitTimeout` of any goroutine, so when other goroutines call those as well, get and prepare
+// SQEs with `PrepareSQE` instead. The file readers and writers only submit their own SQEs.
+// The SQE stays in flight until its completion is reaped, so it is never
+// handed out again while the kernel may still be using it.
+// Error will be nil on success and of type `ErrGetSQE` on failure.
 func (r *Ring) GetEmptySQE() (*SQE, error) {
-	sqe := r.sqes[r.sqeIndex]
-	r.sqeIndex++
-	if r.sqeIndex == r.queueDepth {
-		r.sqeIndex = 0
+	r.sqeMu.Lock()
+	defer r.sqeMu.Unlock()
+
+	return r.getEmptySQE(true)
+}
+
+// PrepareSQE Gets an empty SQE and prepares it with prep, which may also set its flags and data,
+// see `SQE.SetData`. The SQE is only made ready to go out once prep has returned, so a submission
+// from another goroutine never sends it half prepared. It then goes out with the next submission.
+// prep must not take other SQEs from the ring, nor submit.
+// Returns the user data of the SQE, see `SQE.UserData`.
+// Error will be of type `ErrGetSQE`, or the error of prep, in which case the SQE is discarded.
+func (r *Ring) PrepareSQE(prep func(sqe *SQE) error) (uint64, error) {
+	sqe, err := r.getSQE()
+	if err != nil {
+		return 0, err
+	}
+	if err := prep(sqe); err != nil {
+		sqe.Discard()
+		return 0, err
+	}
+
+	userData := sqe.UserData()
+	r.sqeMu.Lock()
+	sqe.markReady()
+	r.sqeMu.Unlock()
+	return userData, nil
+}
+
+// getSQE Gets an empty SQE for `PrepareSQE`, which only goes out once it is made ready.
+func (r *Ring) getSQE() (*SQE, error) {
+	r.sqeMu.Lock()
+	defer r.sqeMu.Unlock()
+
+	return r.getEmptySQE(false)
+}
+
+// getClaimedSQE Gets an empty SQE for the ring itself or a file reader or writer, see `getClaimedSQEs`.
+func (r *Ring) getClaimedSQE(data interface{}) (*SQE, error) {
+	sqes, err := r.getClaimedSQEs(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return sqes[0], nil
+}
+
+// getClaimedSQEs Gets an empty SQE for each value in data, claimed and associated with its value before
+// r.sqeMu is released, so that `WaitCQE` and friends never take their completions, see `SQE.claimed`.
+// They go out next to each other once they are made ready together by `submitSQEs`.
+func (r *Ring) getClaimedSQEs(data ...interface{}) ([]*SQE, error) {
+	r.sqeMu.Lock()
+	defer r.sqeMu.Unlock()
+
+	sqes, err := r.takeSQEs(len(data), false)
+	if err != nil {
+		return nil, err
+	}
+	for i, sqe := range sqes {
+		sqe.claimed = true
+		sqe.data = data[i]
+	}
+
+	return sqes, nil
+}
+
+// getEmptySQEs Gets n empty SQEs that go out next to each other, as links need, provided they are
+// made ready together. Either all n are handed out or none, see `GetEmptySQE` for errors.
+// With readyOnSubmit, they go out with the next `Submit`, like those of `GetEmptySQE`.
+func (r *Ring) getEmptySQEs(n int, readyOnSubmit bool) ([]*SQE, error) {
+	r.sqeMu.Lock()
+	defer r.sqeMu.Unlock()
+
+	return r.takeSQEs(n, readyOnSubmit)
+}
+
+// takeSQEs Gets n empty SQEs, see `getEmptySQEs`, r.sqeMu must be held.
+func (r *Ring) takeSQEs(n int, readyOnSubmit bool) ([]*SQE, error) {
+	if len(r.pending)+n > int(C.io_uring_sq_space_left(r.ring)) {
+		return nil, NewErrGetSQE(ErrSQEFull)
+	}
+
+	sqes := make([]*SQE, 0, n)
+	for len(sqes) < n {
+		sqe, err := r.getEmptySQE(readyOnSubmit)
+		if err != nil {
+			for _, sqe := range sqes {
+				r.dropPending(sqe)
+			}
+			return nil, err
+		}
+		sqes = append(sqes, sqe)
 	}
-	err := sqe.init()
-	return sqe, err
+
+	return sqes, nil
 }
 
-// Submit Submits SQEs.
-func (r *Ring) Submit() {
-	C.io_uring_submit(r.ring)
+// getEmptySQE Gets an empty SQE, r.sqeMu must be held.
+// Every pending SQE has room in the submission queue, so flushing them never runs out of entries.
+func (r *Ring) getEmptySQE(readyOnSubmit bool) (*SQE, error) {
+	if len(r.pending) >= int(C.io_uring_sq_space_left(r.ring)) {
+		return nil, NewErrGetSQE(ErrSQEFull)
+	}
+
+	var sqe *SQE
+	if n := len(r.freeSQEs); n > 0 {
+		sqe = r.freeSQEs[n-1]
+		r.freeSQEs = r.freeSQEs[:n-1]
+	} else {
+		var err error
+		sqe, err = r.newSQE()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sqe.init(readyOnSubmit)
+	r.pending = append(r.pending, sqe)
+	return sqe, nil
+}
+
+// dropPending Takes a pending SQE that will not go out off the pending SQEs and hands it back
+// to the pool, r.sqeMu must be held.
+func (r *Ring) dropPending(sqe *SQE) {
+	for i, pending := range r.pending {
+		if pending == sqe {
+			r.pending = append(r.pending[:i], r.pending[i+1:]...)
+			break
+		}
+	}
+
+	r.sqes.release(sqe.token)
+	sqe.reset()
+	r.freeSQEs = append(r.freeSQEs, sqe)
+}
+
+// InFlight Gets the number of SQEs handed out whose completions have not been reaped yet.
+func (r *Ring) InFlight() int {
+	return r.sqes.len()
+}
+
+func (r *Ring) newSQE() (*SQE, error) {
+	sqe, err := newSQE(r)
+	if err != nil {
+		return nil, err
+	}
+
+	r.allSQEs = append(r.allSQEs, sqe)
+	return sqe, nil
+}
+
+// putSQE Hands an SQE whose completion was reaped back to the pool.
+func (r *Ring) putSQE(sqe *SQE) {
+	r.sqeMu.Lock()
+	defer r.sqeMu.Unlock()
+
+	sqe.reset()
+	r.freeSQEs = append(r.freeSQEs, sqe)
+}
+
+// releaseSQE Hands the SQE of token back to the pool, once nothing refers to it anymore.
+func (r *Ring) releaseSQE(token uint64) {
+	if sqe, ok := r.sqes.release(token); ok {
+		r.putSQE(sqe)
+	}
+}
+
+// Submit Submits SQEs. Returns the number of SQEs submitted.
+// Every SQE handed out by `GetEmptySQE` and not submitted yet goes out, including those of other
+// goroutines, together with those made ready by `PrepareSQE`.
+// Error will be nil on success and of type `ErrSubmit` on failure.
+func (r *Ring) Submit() (int, error) {
+	return r.submit(r.readyEmptySQEs, 0, -1)
+}
+
+// SubmitAndWait Submits SQEs and waits for at least waitNr completions in a single system call.
+// Returns the number of SQEs submitted. The completions can then be reaped without blocking.
+// Error will be nil on success and of type `ErrSubmit` on failure.
+func (r *Ring) SubmitAndWait(waitNr uint32) (int, error) {
+	return r.submit(r.readyEmptySQEs, waitNr, -1)
+}
+
+// SubmitAndWaitTimeout Submits SQEs and waits up to timeout for at least waitNr completions.
+// Returns the number of SQEs submitted, which is still set when the timeout expired.
+// Error will be nil on success and of type `ErrSubmit` on failure,
+// with code `ErrSubmitETIME` if the timeout expired.
+func (r *Ring) SubmitAndWaitTimeout(waitNr uint32, timeout time.Duration) (int, error) {
+	if timeout < 0 {
+		timeout = 0
+	}
+
+	return r.submit(r.readyEmptySQEs, waitNr, timeout)
+}
+
+// submitSQEs Makes sqes ready and submits them, with any other SQE that is ready.
+// Those of `GetEmptySQE` are left for the next `Submit`.
+func (r *Ring) submitSQEs(sqes ...*SQE) (int, error) {
+	return r.submit(func() {
+		for _, sqe := range sqes {
+			sqe.markReady()
+		}
+	}, 0, -1)
+}
+
+// readyEmptySQEs Makes the SQEs of `GetEmptySQE` ready, r.sqeMu must be held.
+func (r *Ring) readyEmptySQEs() {
+	for _, sqe := range r.pending {
+		if sqe.readyOnSubmit {
+			sqe.markReady()
+		}
+	}
+}
+
+// submit Calls ready and flushes the ready SQEs into the submission queue, then enters the kernel to
+// submit them and wait for waitNr completions, for up to timeout unless it is negative, until it is not
+// interrupted. ready is called with r.sqeMu held. Returns the number of SQEs flushed by this call,
+// which are in flight from then on, even if entering fails: the kernel then takes them with the next submission or wait.
+func (r *Ring) submit(ready func(), waitNr uint32, timeout time.Duration) (int, error) {
+	deadline := time.Now().Add(timeout)
+
+	r.sqeMu.Lock()
+	ready()
+	flushed, skipped := r.flush()
+	r.sqeMu.Unlock()
+
+	for _, token := range skipped {
+		r.releaseSQE(token)
+	}
+
+	for {
+		nsec := int64(-1)
+		if timeout >= 0 {
+			if nsec = int64(time.Until(deadline)); nsec < 0 {
+				nsec = 0
+			}
+		}
+
+		ret := C.ring_enter(r.ring, C.sq_unconsumed(r.ring), C.uint(waitNr), C.longlong(nsec))
+		if ret == -C.EINTR {
+			continue
+		}
+		if ret > 0 && waitNr > 0 && uint32(C.io_uring_cq_ready(r.ring)) < waitNr {
+			// Having submitted, the kernel returns the count even if the wait timed out, so wait again to tell.
+			continue
+		}
+		if ret == -C.ETIME {
+			return flushed, NewErrSubmit(ret)
+		}
+		if ret < 0 {
+			return 0, NewErrSubmit(ret)
+		}
+
+		return flushed, nil
+	}
+}
+
+// flush Copies the ready SQEs into the submission queue, in the order they were handed out, marks them
+// as in flight and publishes them to the kernel. The SQEs that are not ready stay pending. r.sqeMu must be held.
+// Returns how many SQEs were flushed, and the tokens of those that get no completion on success, which
+// can be handed back to the pool straight away, since they use no memory of their own, see `SetCQESkipSuccess`.
+// If one fails after all, its completion carries no data.
+func (r *Ring) flush() (int, []uint64) {
+	var skipped []uint64
+	n := 0
+	kept := r.pending[:0]
+	for _, sqe := range r.pending {
+		if !sqe.ready {
+			kept = append(kept, sqe)
+			continue
+		}
+
+		sqe.stamp()
+		if C.queue_sqe(r.ring, sqe.sqe.sqe) == 0 {
+			// Pending SQEs always have room, see `getEmptySQE`.
+			panic("goliburing: submission queue overflow")
+		}
+		sqe.state = sqeInFlight
+		if sqe.skipsSuccess() && !sqe.referencesMemory() {
+			skipped = append(skipped, sqe.token)
+		}
+		n++
+	}
+	for i := len(kept); i < len(r.pending); i++ {
+		r.pending[i] = nil
+	}
+	r.pending = kept
+
+	C.publish_sq(r.ring)
+	return n, skipped
 }
 
 // WaitCQE Wait for a completion queue event.
+// Completions waited for by the ring itself or by the file readers and writers are left to them.
+// Error will be nil on success and of type `ErrWaitCQE` on failure.
 func (r *Ring) WaitCQE() (*CQE, error) {
-	ret := int(C.io_uring_wait_cqe(r.ring, &r.cqe.cqe))
-	if ret != 0 {
-		return nil, fmt.Errorf("WaitCQE failed with %d", ret)
+	return r.reap(unclaimed, -1)
+}
+
+// WaitCQETimeout Wait up to timeout for a completion queue event.
+// Error will be nil on success and of type `ErrWaitCQE` on failure,
+// with code `ErrWaitCQEETIME` if the timeout expired.
+func (r *Ring) WaitCQETimeout(timeout time.Duration) (*CQE, error) {
+	if timeout <= 0 {
+		// Still reap a completion that is already there.
+		cqe, err := r.reap(unclaimed, 0)
+		if e, ok := err.(*ErrWaitCQE); ok && e.Code == ErrWaitCQEEAGAIN {
+			return nil, NewErrWaitCQE(-C.ETIME, nil)
+		}
+		return cqe, err
 	}
 
-	return r.cqe, nil
+	return r.reap(unclaimed, timeout)
+}
+
+// PeekCQE Gets a completion queue event without blocking.
+// Unlike io_uring_peek_cqe, the completion is taken out of the completion queue, so peeking
+// again gets the next one, even before it is marked as seen. See `PeekBatchCQE` to look without taking.
+// Error will be nil on success and of type `ErrWaitCQE` on failure,
+// with code `ErrWaitCQEEAGAIN` if no completion is available.
+// While another goroutine blocks waiting for completions, those it has not taken out of
+// the completion queue yet are left to it, and are seen once it has.
+func (r *Ring) PeekCQE() (*CQE, error) {
+	return r.reap(unclaimed, 0)
+}
+
+// PeekBatchCQE Copies up to len(dst) completion queue events into dst without blocking,
+// and returns how many were copied. The events are not marked as seen,
+// call `CQAdvance` with the returned count once they have been handled,
+// before reaping completions in any other way. Like `PeekCQE`, it leaves the completions
+// in the completion queue to a goroutine blocked waiting for them.
+func (r *Ring) PeekBatchCQE(dst []CQE) int {
+	r.cqMu.Lock()
+	defer r.cqMu.Unlock()
+
+	r.drainCQ()
+	n := 0
+	for i := range r.backlog {
+		if n == len(dst) {
+			break
+		}
+		cqe := &r.backlog[i]
+		if !r.matchCQE(cqe, unclaimed) {
+			continue
+		}
+
+		dst[n] = CQE{ring: r}
+		dst[n].load(cqe)
+		// Their SQEs are handed back by `CQAdvance`.
+		dst[n].last = false
+		n++
+	}
+
+	return n
+}
+
+// CQAdvance Marks the next n completion queue events as seen,
+// and hands back the SQEs of those that were the last of their SQE.
+func (r *Ring) CQAdvance(n uint32) {
+	r.cqMu.Lock()
+	defer r.cqMu.Unlock()
+
+	r.drainCQ()
+	kept := r.backlog[:0]
+	for i := range r.backlog {
+		cqe := &r.backlog[i]
+		if n > 0 && r.matchCQE(cqe, unclaimed) {
+			r.releaseCQE(cqe)
+			n--
+			continue
+		}
+		kept = append(kept, *cqe)
+	}
+	r.backlog = kept
+}
+
+// releaseCQE Hands back the SQE of cqe, unless more completions will follow for it.
+func (r *Ring) releaseCQE(cqe *C.struct_io_uring_cqe) {
+	if CQEFlag(cqe.flags)&IORingCQEFMore == 0 {
+		r.releaseSQE(uint64(cqe.user_data))
+	}
+}
+
+// unclaimed Accepts the completions that are handed out by `WaitCQE` and friends,
+// those whose SQE is not claimed, see `SQE.claimed`, or is already gone.
+func unclaimed(sqe *SQE) bool {
+	return sqe == nil || !sqe.claimed
+}
+
+// matchCQE Reports whether match accepts the SQE cqe belongs to, or nil if it was already handed back.
+// The SQE is read under r.sqeMu, which its owner released after preparing it.
+func (r *Ring) matchCQE(cqe *C.struct_io_uring_cqe, match func(sqe *SQE) bool) bool {
+	r.sqeMu.Lock()
+	defer r.sqeMu.Unlock()
+
+	sqe, _ := r.sqes.lookup(uint64(cqe.user_data))
+	return match(sqe)
+}
+
+// reap Waits up to timeout for a completion of an SQE that match accepts, with a negative
+// timeout waiting forever and a zero one not blocking. The completions that match does not accept
+// are taken out of the completion queue into the backlog, where they wait for their own reaper.
+// Only one goroutine blocks in the kernel at a time, the others wait for it to fill the backlog.
+// Meanwhile nothing else takes completions out of the completion queue, see `takeCQE`.
+// Error will be of type `ErrWaitCQE`, with code `ErrWaitCQEEAGAIN` if timeout is zero and
+// no completion is available, or `ErrWaitCQEETIME` if the timeout expired.
+func (r *Ring) reap(match func(sqe *SQE) bool, timeout time.Duration) (*CQE, error) {
+	deadline := time.Now().Add(timeout)
+
+	r.cqMu.Lock()
+	defer r.cqMu.Unlock()
+
+	for {
+		if cqe := r.takeCQE(match); cqe != nil {
+			return cqe, nil
+		}
+
+		remaining := time.Duration(-1)
+		if timeout == 0 {
+			return nil, NewErrWaitCQE(-C.EAGAIN, nil)
+		} else if timeout > 0 {
+			if remaining = time.Until(deadline); remaining <= 0 {
+				return nil, NewErrWaitCQE(-C.ETIME, nil)
+			}
+		}
+
+		if r.reaping {
+			r.waitReaped(remaining)
+			continue
+		}
+
+		r.reaping = true
+		r.cqMu.Unlock()
+		ret := r.waitCQ(remaining)
+		r.cqMu.Lock()
+		r.reaping = false
+		r.notifyReaped()
+
+		if ret < 0 && ret != -C.EINTR && ret != -C.ETIME {
+			return nil, NewErrWaitCQE(ret, nil)
+		}
+	}
+}
+
+// takeCQE Takes the oldest completion match accepts out of the backlog, or else out of
+// the completion queue, and loads it. Returns nil if there is none, r.cqMu must be held.
+// While a goroutine blocks in the kernel, only the backlog is looked at: taking its completion out of
+// the completion queue would leave it blocked for good, and it takes the completions out itself once woken.
+func (r *Ring) takeCQE(match func(sqe *SQE) bool) *CQE {
+	for i := range r.backlog {
+		if r.matchCQE(&r.backlog[i], match) {
+			cqe := newCQE(r)
+			cqe.load(&r.backlog[i])
+			r.backlog = append(r.backlog[:i], r.backlog[i+1:]...)
+			return cqe
+		}
+	}
+	if r.reaping {
+		return nil
+	}
+
+	grew := false
+	defer func() {
+		if grew {
+			r.notifyReaped()
+		}
+	}()
+
+	for {
+		raw, ok := r.popCQ()
+		if !ok {
+			return nil
+		}
+		if r.matchCQE(&raw, match) {
+			cqe := newCQE(r)
+			cqe.load(&raw)
+			return cqe
+		}
+
+		r.backlog = append(r.backlog, raw)
+		grew = true
+	}
+}
+
+// drainCQ Takes every completion out of the completion queue into the backlog, r.cqMu must be held.
+// Like `takeCQE`, it leaves them to the goroutine blocked in the kernel, if any.
+func (r *Ring) drainCQ() {
+	if r.reaping {
+		return
+	}
+
+	grew := false
+	for {
+		raw, ok := r.popCQ()
+		if !ok {
+			break
+		}
+		r.backlog = append(r.backlog, raw)
+		grew = true
+	}
+
+	if grew {
+		r.notifyReaped()
+	}
+}
+
+// popCQ Copies the oldest completion out of the completion queue and marks it as seen. r.cqMu must be held.
+func (r *Ring) popCQ() (C.struct_io_uring_cqe, bool) {
+	var cqe *C.struct_io_uring_cqe
+	if C.io_uring_peek_cqe(r.ring, &cqe) != 0 {
+		return C.struct_io_uring_cqe{}, false
+	}
+	raw := *cqe
+	C.io_uring_cqe_seen(r.ring, cqe)
+
+	return raw, true
+}
+
+// waitCQ Blocks in the kernel until the completion queue is not empty, for up to timeout
+// unless it is negative, without taking anything out of it. The entries published to the kernel
+// that it has not taken yet, such as after a failed submission, are submitted along the way.
+func (r *Ring) waitCQ(timeout time.Duration) C.int {
+	return C.ring_enter(r.ring, C.sq_unconsumed(r.ring), 1, C.longlong(timeout))
+}
+
+// waitReaped Waits up to timeout, unless it is negative, for the goroutine blocked in the kernel
+// to take completions into the backlog or to stop. r.cqMu must be held, and is released meanwhile.
+func (r *Ring) waitReaped(timeout time.Duration) {
+	reaped := r.reaped
+	r.cqMu.Unlock()
+	defer r.cqMu.Lock()
+
+	if timeout < 0 {
+		<-reaped
+		return
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-reaped:
+	case <-timer.C:
+	}
+}
+
+// notifyReaped Wakes the goroutines waiting in `waitReaped`, r.cqMu must be held.
+func (r *Ring) notifyReaped() {
+	close(r.reaped)
+	r.reaped = make(chan struct{})
+}
+
+// WaitCQEContext Wait for a completion queue event until ctx is done.
+// The calling goroutine never blocks in the kernel for longer than a few milliseconds,
+// so it returns promptly once ctx is cancelled.
+// Error will be nil on success and of type `ErrWaitCQE` on failure,
+// with code `ErrWaitCQEECANCELED` or `ErrWaitCQEETIME` if ctx was cancelled or its deadline passed.
+func (r *Ring) WaitCQEContext(ctx context.Context) (*CQE, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			if err == context.DeadlineExceeded {
+				return nil, NewErrWaitCQE(-C.ETIME, err)
+			}
+			return nil, NewErrWaitCQE(-C.ECANCELED, err)
+		}
+
+		wait := waitCQEContextInterval
+		if deadline, ok := ctx.Deadline(); ok {
+			if remaining := time.Until(deadline); remaining < wait {
+				wait = remaining
+			}
+		}
+
+		cqe, err := r.WaitCQETimeout(wait)
+		if e, ok := err.(*ErrWaitCQE); ok && e.Code == ErrWaitCQEETIME {
+			continue
+		}
+
+		return cqe, err
+	}
 }
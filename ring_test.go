@@ -1,10 +1,33 @@
 package goliburing
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
 	"os"
+	"syscall"
 	"testing"
+	"time"
 )
 
+// prepSubmit Gets an SQE from ring, prepares it with prep and submits it.
+func prepSubmit(t *testing.T, ring *Ring, prep func(sqe *SQE) error) uint64 {
+	sqe, err := ring.GetEmptySQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := prep(sqe); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ring.Submit(); err != nil {
+		t.Fatal(err)
+	}
+
+	return sqe.UserData()
+}
+
 func TestNewRing(t *testing.T) {
 	var queueDepth uint32 = 128
 	ring, err := NewRing(queueDepth, nil)
@@ -34,7 +57,9 @@ func TestPrepWriteV(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	sqe.PrepWriteV(int(f.Fd()), data, 0)
+	if err := sqe.PrepWriteV(int(f.Fd()), [][]byte{data}, 0); err != nil {
+		t.Fatal(err)
+	}
 	ring.Submit()
 	cqe, err := ring.WaitCQE()
 	if err != nil {
@@ -44,6 +69,646 @@ func TestPrepWriteV(t *testing.T) {
 	os.Remove(f.Name())
 }
 
+func TestPrepWriteVBuffers(t *testing.T) {
+	ring, err := NewRing(128, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	f, err := os.OpenFile("tmp-prep-write-v-buffers", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	bufs := net.Buffers{[]byte("header|"), []byte("payload|"), []byte("trailer")}
+	sqe, err := ring.GetEmptySQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sqe.PrepWriteV(int(f.Fd()), bufs, 0); err != nil {
+		t.Fatal(err)
+	}
+	ring.Submit()
+	cqe, err := ring.WaitCQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := cqe.Result()
+	cqe.Seen()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "header|payload|trailer"
+	if want, have := len(want), n; want != have {
+		t.Fatalf("PrepWriteV: want %d, have %d", want, have)
+	}
+
+	have, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want != string(have) {
+		t.Fatalf("PrepWriteV: want %q, have %q", want, have)
+	}
+}
+
+func TestGetEmptySQEBeyondQueueDepth(t *testing.T) {
+	var queueDepth uint32 = 2
+	ring, err := NewRing(queueDepth, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	f, err := os.OpenFile("tmp-get-empty-sqe", os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := f.Write([]byte("0123")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Keep more reads outstanding than the queue depth, each with its own iovec.
+	count := 2 * int(queueDepth)
+	bufs := make([][]byte, count)
+	sqes := make(map[*SQE]bool)
+	for i := 0; i < count; i++ {
+		sqe, err := ring.GetEmptySQE()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sqes[sqe] {
+			t.Fatalf("GetEmptySQE: SQE %d is still in flight", i)
+		}
+		sqes[sqe] = true
+
+		bufs[i] = make([]byte, 1)
+		if err := sqe.PrepReadV(int(f.Fd()), [][]byte{bufs[i]}, uint64(i)); err != nil {
+			t.Fatal(err)
+		}
+		sqe.SetData(i)
+		if _, err := ring.Submit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if want, have := count, ring.InFlight(); want != have {
+		t.Fatalf("InFlight: want %d, have %d", want, have)
+	}
+
+	for i := 0; i < count; i++ {
+		cqe, err := ring.WaitCQE()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := cqe.Result(); err != nil {
+			t.Fatal(err)
+		}
+		cqe.Seen()
+	}
+
+	for i, buf := range bufs {
+		if want, have := byte('0'+i), buf[0]; want != have {
+			t.Fatalf("read %d: want %q, have %q", i, want, have)
+		}
+	}
+	if want, have := 0, ring.InFlight(); want != have {
+		t.Fatalf("InFlight: want %d, have %d", want, have)
+	}
+}
+
+func TestPrepRead(t *testing.T) {
+	ring, err := NewRing(128, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	f, err := os.OpenFile("tmp-prep-read", os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	want := []byte("hello, io_uring")
+	if _, err := f.Write(want); err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, 256)
+	sqe, err := ring.GetEmptySQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqe.PrepRead(int(f.Fd()), data, 0)
+	ring.Submit()
+	cqe, err := ring.WaitCQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := cqe.Result()
+	cqe.Seen()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have := string(data[:n]); string(want) != have {
+		t.Fatalf("PrepRead: want %q, have %q", want, have)
+	}
+}
+
+func TestPrepReadV(t *testing.T) {
+	ring, err := NewRing(128, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	f, err := os.OpenFile("tmp-prep-read-v", os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := f.Write([]byte("headerpayload")); err != nil {
+		t.Fatal(err)
+	}
+
+	header := make([]byte, 6)
+	payload := make([]byte, 7)
+	sqe, err := ring.GetEmptySQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sqe.PrepReadV(int(f.Fd()), [][]byte{header, payload}, 0); err != nil {
+		t.Fatal(err)
+	}
+	ring.Submit()
+	cqe, err := ring.WaitCQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := cqe.Result()
+	cqe.Seen()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want, have := len(header)+len(payload), n; want != have {
+		t.Fatalf("PrepReadV: want %d, have %d", want, have)
+	}
+	if want, have := "header", string(header); want != have {
+		t.Fatalf("PrepReadV header: want %q, have %q", want, have)
+	}
+	if want, have := "payload", string(payload); want != have {
+		t.Fatalf("PrepReadV payload: want %q, have %q", want, have)
+	}
+}
+
+func TestWaitCQETimeout(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	start := time.Now()
+	_, err = ring.WaitCQETimeout(20 * time.Millisecond)
+	e, ok := err.(*ErrWaitCQE)
+	if !ok || e.Code != ErrWaitCQEETIME || !e.Timeout() {
+		t.Fatalf("WaitCQETimeout: want ErrWaitCQEETIME, have %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Fatalf("WaitCQETimeout: returned after %v", elapsed)
+	}
+
+	sqe, err := ring.GetEmptySQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqe.PrepRead(-1, make([]byte, 16), 0)
+	ring.Submit()
+	cqe, err := ring.WaitCQETimeout(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cqe.Seen()
+}
+
+func TestWaitCQEContext(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err = ring.WaitCQEContext(ctx)
+	if e, ok := err.(*ErrWaitCQE); !ok || e.Code != ErrWaitCQEECANCELED {
+		t.Fatalf("WaitCQEContext: want ErrWaitCQEECANCELED, have %v", err)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("WaitCQEContext: want context.Canceled, have %v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = ring.WaitCQEContext(ctx)
+	if e, ok := err.(*ErrWaitCQE); !ok || !e.Timeout() {
+		t.Fatalf("WaitCQEContext: want timeout, have %v", err)
+	}
+}
+
+func TestSubmitAndWait(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	const count = 3
+	for i := 0; i < count; i++ {
+		sqe, err := ring.GetEmptySQE()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sqe.PrepRead(-1, make([]byte, 16), 0)
+	}
+
+	submitted, err := ring.SubmitAndWait(count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := count, submitted; want != have {
+		t.Fatalf("SubmitAndWait: want %d, have %d", want, have)
+	}
+
+	cqes := make([]CQE, count)
+	if want, have := count, ring.PeekBatchCQE(cqes); want != have {
+		t.Fatalf("PeekBatchCQE: want %d, have %d", want, have)
+	}
+	ring.CQAdvance(count)
+}
+
+func TestSubmitAndWaitTimeout(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	_, err = ring.SubmitAndWaitTimeout(1, 20*time.Millisecond)
+	if e, ok := err.(*ErrSubmit); !ok || e.Code != ErrSubmitETIME {
+		t.Fatalf("SubmitAndWaitTimeout: want ErrSubmitETIME, have %v", err)
+	}
+
+	sqe, err := ring.GetEmptySQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqe.PrepRead(-1, make([]byte, 16), 0)
+	submitted, err := ring.SubmitAndWaitTimeout(1, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if submitted < 1 {
+		t.Fatalf("SubmitAndWaitTimeout: want the read submitted, have %d", submitted)
+	}
+
+	cqe, err := ring.PeekCQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cqe.Seen()
+
+	// A poll that never completes is still submitted when the wait times out.
+	fds := make([]int, 2)
+	if err := syscall.Pipe(fds); err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Close(fds[0])
+	defer syscall.Close(fds[1])
+	sqe, err = ring.GetEmptySQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqe.PrepPollAdd(fds[0], PollIn)
+	submitted, err = ring.SubmitAndWaitTimeout(1, 20*time.Millisecond)
+	if e, ok := err.(*ErrSubmit); !ok || e.Code != ErrSubmitETIME {
+		t.Fatalf("SubmitAndWaitTimeout: want ErrSubmitETIME, have %v", err)
+	}
+	if submitted < 1 {
+		t.Fatalf("SubmitAndWaitTimeout: want the poll submitted, have %d", submitted)
+	}
+}
+
+func TestSQEFlags(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	f, err := os.OpenFile("/tmp/sqe-flags-test", os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	// The drained read only starts once the write before it has completed.
+	fd := int(f.Fd())
+	sqe, err := ring.GetEmptySQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sqe.PrepWriteV(fd, [][]byte{[]byte("hello")}, 0); err != nil {
+		t.Fatal(err)
+	}
+	sqe.SetAsync()
+
+	have := make([]byte, 5)
+	sqe, err = ring.GetEmptySQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqe.PrepRead(fd, have, 0)
+	sqe.SetDrain()
+	ring.Submit()
+
+	for i := 0; i < 2; i++ {
+		cqe, err := ring.WaitCQE()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := cqe.Result(); err != nil {
+			t.Fatal(err)
+		}
+		cqe.Seen()
+	}
+	if want := "hello"; want != string(have) {
+		t.Fatalf("Read: want %q, have %q", want, have)
+	}
+}
+
+func TestSQECQESkipSuccess(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	if !ring.hasFeature(IORingFeatCQESkip) {
+		t.Skip("IOSQE_CQE_SKIP_SUCCESS not supported")
+	}
+
+	f, err := ioutil.TempFile("", "sqe-cqe-skip-success-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	// A read uses its buffer until it completes, so its completion cannot be skipped.
+	sqe, err := ring.GetEmptySQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqe.PrepRead(int(f.Fd()), make([]byte, 16), 0)
+	if e, ok := sqe.SetCQESkipSuccess().(*ErrGetSQE); !ok || e.Code != ErrSQESkipSuccess {
+		t.Fatalf("SetCQESkipSuccess on a read: want ErrSQESkipSuccess, have %v", e)
+	}
+	if err := sqe.Discard(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the failed fsync posts a completion.
+	for _, fd := range []int{int(f.Fd()), -1} {
+		sqe, err := ring.GetEmptySQE()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sqe.PrepFsync(fd, false)
+		sqe.SetData(fd)
+		if err := sqe.SetCQESkipSuccess(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := ring.Submit(); err != nil {
+		t.Fatal(err)
+	}
+
+	cqe, err := ring.WaitCQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cqe.Result(); err != syscall.EBADF {
+		t.Fatalf("Result: want %v, have %v", syscall.EBADF, err)
+	}
+	cqe.Seen()
+
+	if _, err := ring.WaitCQETimeout(10 * time.Millisecond); err == nil {
+		t.Fatal("WaitCQETimeout: want no completion for the successful fsync")
+	}
+	if want, have := 0, ring.InFlight(); want != have {
+		t.Fatalf("InFlight: want %d, have %d", want, have)
+	}
+}
+
+func TestSQEDiscard(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	sqe, err := ring.GetEmptySQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqe.PrepRead(-1, make([]byte, 16), 0)
+	sqe.SetData("discarded")
+	if err := sqe.Discard(); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 0, ring.InFlight(); want != have {
+		t.Fatalf("InFlight: want %d, have %d", want, have)
+	}
+	if submitted, err := ring.Submit(); err != nil || submitted != 0 {
+		t.Fatalf("Submit: want 0, have %d (%v)", submitted, err)
+	}
+	if _, err := ring.PeekCQE(); err == nil {
+		t.Fatal("PeekCQE: want no completion for a discarded SQE")
+	}
+
+	sqe, err = ring.GetEmptySQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqe.PrepRead(-1, make([]byte, 16), 0)
+	if _, err := ring.Submit(); err != nil {
+		t.Fatal(err)
+	}
+	if e, ok := sqe.Discard().(*ErrGetSQE); !ok || e.Code != ErrSQEInFlight {
+		t.Fatalf("Discard after Submit: want ErrSQEInFlight, have %v", e)
+	}
+	cqe, err := ring.WaitCQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cqe.Seen()
+}
+
+func TestPrepareSQEConcurrent(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	const goroutines, reads, size = 2, 64, 16
+	content := make([]byte, goroutines*reads*size)
+	for i := range content {
+		content[i] = byte(i / size)
+	}
+	f, err := ioutil.TempFile("", "prepare-sqe-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := f.Write(content); err != nil {
+		t.Fatal(err)
+	}
+
+	bufs := make([][]byte, goroutines*reads)
+	for i := range bufs {
+		bufs[i] = make([]byte, size)
+	}
+
+	// Bound the reads in flight so the completion queue never overflows.
+	inFlight := make(chan struct{}, 8)
+	errs := make(chan error, goroutines)
+	for g := 0; g < goroutines; g++ {
+		go func(g int) {
+			for i := 0; i < reads; i++ {
+				block := g*reads + i
+				inFlight <- struct{}{}
+				for {
+					_, err := ring.PrepareSQE(func(sqe *SQE) error {
+						sqe.PrepRead(int(f.Fd()), bufs[block], uint64(block*size))
+						sqe.SetData(block)
+						return nil
+					})
+					if e, ok := err.(*ErrGetSQE); ok && e.Code == ErrSQEFull {
+						time.Sleep(time.Millisecond)
+						continue
+					}
+					if err != nil {
+						errs <- err
+						return
+					}
+					break
+				}
+				if _, err := ring.Submit(); err != nil {
+					errs <- err
+					return
+				}
+			}
+			errs <- nil
+		}(g)
+	}
+
+	seen := make(map[int]bool)
+	for len(seen) < goroutines*reads {
+		cqe, err := ring.WaitCQE()
+		if err != nil {
+			t.Fatal(err)
+		}
+		block, ok := cqe.Data().(int)
+		if !ok || seen[block] {
+			t.Fatalf("Data: want a block read once, have %v", cqe.Data())
+		}
+		seen[block] = true
+		if want, have := int32(size), cqe.Res(); want != have {
+			t.Fatalf("Res: want %d, have %d", want, have)
+		}
+		if want, have := content[block*size:(block+1)*size], bufs[block]; !bytes.Equal(want, have) {
+			t.Fatalf("block %d: want %v, have %v", block, want, have)
+		}
+		cqe.Seen()
+		<-inFlight
+	}
+	for g := 0; g < goroutines; g++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPrepFsync(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	f, err := ioutil.TempFile("", "prep-fsync-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	fd := int(f.Fd())
+	preps := []func(sqe *SQE){
+		func(sqe *SQE) { sqe.PrepFsync(fd, false) },
+		func(sqe *SQE) { sqe.PrepFsync(fd, true) },
+		func(sqe *SQE) {
+			sqe.PrepSyncFileRange(fd, 0, 5, SyncFileRangeWaitBefore|SyncFileRangeWrite|SyncFileRangeWaitAfter)
+		},
+	}
+	for i, prep := range preps {
+		// Link the sync to the write, so it only starts once the data is written.
+		chain := NewChain(ring).
+			Add(func(sqe *SQE) error {
+				return sqe.PrepWriteV(fd, [][]byte{[]byte("hello")}, 0)
+			}).
+			Add(func(sqe *SQE) error {
+				prep(sqe)
+				return nil
+			})
+		if _, err := chain.Submit(); err != nil {
+			t.Fatal(err)
+		}
+
+		for j := 0; j < chain.Len(); j++ {
+			cqe, err := ring.WaitCQE()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := cqe.Result(); err != nil {
+				t.Fatalf("sync %d: %v", i, err)
+			}
+			cqe.Seen()
+		}
+	}
+}
+
 func BenchmarkPrepWriteV(b *testing.B) {
 	ring, err := NewRing(128, nil)
 	if err != nil {
@@ -66,7 +731,10 @@ func BenchmarkPrepWriteV(b *testing.B) {
 			b.Error(err)
 			return
 		}
-		sqe.PrepWriteV(int(f.Fd()), data, 0)
+		if err := sqe.PrepWriteV(int(f.Fd()), [][]byte{data}, 0); err != nil {
+			b.Error(err)
+			return
+		}
 		ring.Submit()
 		cqe, err := ring.WaitCQE()
 		if err != nil {
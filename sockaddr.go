@@ -0,0 +1,132 @@
+package goliburing
+
+/*
+#include <netinet/in.h>
+#include <sys/socket.h>
+#include <sys/un.h>
+*/
+import "C"
+import (
+	"syscall"
+	"unsafe"
+)
+
+// putSockaddr Marshals sa into the sockaddr_storage at dst, the way the syscall package does,
+// and returns its length. Error will be syscall.EAFNOSUPPORT for other address types,
+// or syscall.EINVAL for an invalid address.
+func putSockaddr(dst *C.struct_sockaddr_storage, sa syscall.Sockaddr) (C.socklen_t, error) {
+	switch sa := sa.(type) {
+	case *syscall.SockaddrInet4:
+		if sa.Port < 0 || sa.Port > 0xffff {
+			return 0, syscall.EINVAL
+		}
+		raw := (*C.struct_sockaddr_in)(unsafe.Pointer(dst))
+		*raw = C.struct_sockaddr_in{}
+		raw.sin_family = syscall.AF_INET
+		putPort(unsafe.Pointer(&raw.sin_port), sa.Port)
+		*(*[4]byte)(unsafe.Pointer(&raw.sin_addr)) = sa.Addr
+		return C.socklen_t(unsafe.Sizeof(*raw)), nil
+
+	case *syscall.SockaddrInet6:
+		if sa.Port < 0 || sa.Port > 0xffff {
+			return 0, syscall.EINVAL
+		}
+		raw := (*C.struct_sockaddr_in6)(unsafe.Pointer(dst))
+		*raw = C.struct_sockaddr_in6{}
+		raw.sin6_family = syscall.AF_INET6
+		putPort(unsafe.Pointer(&raw.sin6_port), sa.Port)
+		raw.sin6_scope_id = C.uint32_t(sa.ZoneId)
+		*(*[16]byte)(unsafe.Pointer(&raw.sin6_addr)) = sa.Addr
+		return C.socklen_t(unsafe.Sizeof(*raw)), nil
+
+	case *syscall.SockaddrUnix:
+		raw := (*C.struct_sockaddr_un)(unsafe.Pointer(dst))
+		name := sa.Name
+		n := len(name)
+		if n > len(raw.sun_path) || n == len(raw.sun_path) && name[0] != '@' {
+			return 0, syscall.EINVAL
+		}
+		*raw = C.struct_sockaddr_un{}
+		raw.sun_family = syscall.AF_UNIX
+		for i := 0; i < n; i++ {
+			raw.sun_path[i] = C.char(name[i])
+		}
+
+		// The length is the family, the name and its NUL.
+		length := C.socklen_t(unsafe.Offsetof(raw.sun_path))
+		if n > 0 {
+			length += C.socklen_t(n) + 1
+		}
+		if n > 0 && name[0] == '@' {
+			// An abstract address starts with a NUL instead, and is not NUL terminated.
+			raw.sun_path[0] = 0
+			length--
+		}
+		return length, nil
+	}
+
+	return 0, syscall.EAFNOSUPPORT
+}
+
+// getSockaddr Unmarshals the length bytes of the address at src, or gets nil if it is empty
+// or of another family than AF_INET, AF_INET6 or AF_UNIX.
+func getSockaddr(src *C.struct_sockaddr_storage, length C.socklen_t) syscall.Sockaddr {
+	if length < C.socklen_t(unsafe.Sizeof(src.ss_family)) {
+		return nil
+	}
+
+	switch src.ss_family {
+	case syscall.AF_INET:
+		raw := (*C.struct_sockaddr_in)(unsafe.Pointer(src))
+		return &syscall.SockaddrInet4{
+			Port: getPort(unsafe.Pointer(&raw.sin_port)),
+			Addr: *(*[4]byte)(unsafe.Pointer(&raw.sin_addr)),
+		}
+
+	case syscall.AF_INET6:
+		raw := (*C.struct_sockaddr_in6)(unsafe.Pointer(src))
+		return &syscall.SockaddrInet6{
+			Port:   getPort(unsafe.Pointer(&raw.sin6_port)),
+			ZoneId: uint32(raw.sin6_scope_id),
+			Addr:   *(*[16]byte)(unsafe.Pointer(&raw.sin6_addr)),
+		}
+
+	case syscall.AF_UNIX:
+		raw := (*C.struct_sockaddr_un)(unsafe.Pointer(src))
+		n := int(length) - int(unsafe.Offsetof(raw.sun_path))
+		if n > len(raw.sun_path) {
+			n = len(raw.sun_path)
+		}
+		if n <= 0 {
+			return &syscall.SockaddrUnix{}
+		}
+
+		path := make([]byte, 0, n)
+		for i := 0; i < n; i++ {
+			if raw.sun_path[i] == 0 && i > 0 {
+				break
+			}
+			path = append(path, byte(raw.sun_path[i]))
+		}
+		if path[0] == 0 {
+			// An abstract address, which the syscall package writes with a leading @.
+			path[0] = '@'
+		}
+		return &syscall.SockaddrUnix{Name: string(path)}
+	}
+
+	return nil
+}
+
+// putPort Writes port at p in network byte order.
+func putPort(p unsafe.Pointer, port int) {
+	b := (*[2]byte)(p)
+	b[0] = byte(port >> 8)
+	b[1] = byte(port)
+}
+
+// getPort Reads a port in network byte order at p.
+func getPort(p unsafe.Pointer) int {
+	b := (*[2]byte)(p)
+	return int(b[0])<<8 | int(b[1])
+}
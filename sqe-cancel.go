@@ -0,0 +1,19 @@
+package goliburing
+
+/*
+#include "liburing.h"
+*/
+import "C"
+
+// PrepCancel Prepare the cancellation of the request submitted with userData, see `SQE.UserData`
+// and `CancelFlag`. A canceled request completes with ECANCELED. The cancellation itself completes
+// with 0, with the number of requests canceled if `IORingAsyncCancelAll` is set, with ENOENT if no
+// request matched, or with EALREADY if the request was already running and may still complete normally.
+func (s *SQE) PrepCancel(userData uint64, flags CancelFlag) {
+	C.io_uring_prep_cancel64(s.sqe.sqe, C.__u64(userData), C.int(flags))
+}
+
+// PrepCancelFd Prepare the cancellation of the requests on fd, see `PrepCancel`.
+func (s *SQE) PrepCancelFd(fd int, flags CancelFlag) {
+	C.io_uring_prep_cancel_fd(s.sqe.sqe, C.int(fd), C.uint(flags))
+}
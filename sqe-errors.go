@@ -10,6 +10,12 @@ const (
 	ErrSQEMalloc ErrGetSQECode = 1
 	// ErrSQEFull Submission queue is full.
 	ErrSQEFull = 2
+	// ErrSQEInFlight The SQE was already submitted.
+	ErrSQEInFlight = 3
+	// ErrSQESkipSuccess The completion of the SQE cannot be skipped, since the kernel uses memory that must stay valid until then.
+	ErrSQESkipSuccess = 4
+	// ErrSQENotLast The SQE is not the last one taken from the submission queue.
+	ErrSQENotLast = 5
 )
 
 // ErrGetSQE Represents an error while fetching an empty SQE.
@@ -25,6 +31,12 @@ func NewErrGetSQE(code ErrGetSQECode) *ErrGetSQE {
 		message = "could not allocate memory"
 	} else if code == ErrSQEFull {
 		message = "submission queue is full"
+	} else if code == ErrSQEInFlight {
+		message = "entry was already submitted"
+	} else if code == ErrSQESkipSuccess {
+		message = "entry uses memory that must outlive it, its completion cannot be skipped"
+	} else if code == ErrSQENotLast {
+		message = "entry is not the last one taken from the submission queue"
 	} else {
 		message = fmt.Sprintf("unknown error code %d", code)
 	}
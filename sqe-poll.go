@@ -0,0 +1,26 @@
+package goliburing
+
+/*
+#include "liburing.h"
+*/
+import "C"
+
+// PrepPollAdd Prepare a poll of fd for events, see `PollEvent`. It completes once, as soon as
+// any of the events is ready, with the ready events available through `CQE.PollEvents`.
+func (s *SQE) PrepPollAdd(fd int, events PollEvent) {
+	C.io_uring_prep_poll_add(s.sqe.sqe, C.int(fd), C.uint(events))
+}
+
+// PrepPollMultishot Prepare a poll of fd for events that keeps posting a completion every time
+// any of the events becomes ready, until it is removed with `PrepPollRemove` or fails.
+// Every completion but the last reports `CQE.More`, and the SQE stays in flight until the last one.
+func (s *SQE) PrepPollMultishot(fd int, events PollEvent) {
+	C.io_uring_prep_poll_multishot(s.sqe.sqe, C.int(fd), C.uint(events))
+}
+
+// PrepPollRemove Prepare the removal of the poll submitted with userData, see `SQE.UserData`.
+// The poll then completes with ECANCELED, and the removal itself completes with 0,
+// or with ENOENT if no such poll was in flight.
+func (s *SQE) PrepPollRemove(userData uint64) {
+	C.io_uring_prep_poll_remove(s.sqe.sqe, C.__u64(userData))
+}
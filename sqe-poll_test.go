@@ -0,0 +1,140 @@
+package goliburing
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestPrepPollAdd(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	sqe, err := ring.GetEmptySQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqe.PrepPollAdd(int(r.Fd()), PollIn)
+	ring.Submit()
+
+	if _, err := ring.WaitCQETimeout(10 * time.Millisecond); err == nil {
+		t.Fatal("WaitCQETimeout: want no completion before the pipe is readable")
+	}
+
+	if _, err := w.Write([]byte("ready")); err != nil {
+		t.Fatal(err)
+	}
+	cqe, err := ring.WaitCQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cqe.Seen()
+
+	if cqe.PollEvents()&PollIn == 0 {
+		t.Fatalf("PollEvents: want %#x set, have %#x", PollIn, cqe.PollEvents())
+	}
+	if cqe.More() {
+		t.Fatal("More: want false for a single shot poll")
+	}
+}
+
+func TestPrepPollMultishot(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	sqe, err := ring.GetEmptySQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqe.PrepPollMultishot(int(r.Fd()), PollIn)
+	poll := sqe.SetData("poll")
+	ring.Submit()
+
+	// Every write posts another completion, the data stays with the poll.
+	buf := make([]byte, 16)
+	for i := 0; i < 3; i++ {
+		if _, err := w.Write([]byte("ready")); err != nil {
+			t.Fatal(err)
+		}
+		cqe, err := ring.WaitCQE()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cqe.More() || cqe.PollEvents()&PollIn == 0 || cqe.Data() != "poll" {
+			t.Fatalf("completion %d: want more, PollIn and data, have %v, %#x and %v", i, cqe.More(), cqe.PollEvents(), cqe.Data())
+		}
+		cqe.Seen()
+
+		if _, err := r.Read(buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want, have := 1, ring.InFlight(); want != have {
+		t.Fatalf("InFlight: want %d, have %d", want, have)
+	}
+
+	sqe, err = ring.GetEmptySQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqe.PrepPollRemove(poll)
+	remove := sqe.UserData()
+	ring.Submit()
+
+	for i := 0; i < 2; i++ {
+		cqe, err := ring.WaitCQE()
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch cqe.UserData() {
+		case poll:
+			if !cqe.Canceled() || cqe.More() {
+				t.Fatalf("removed poll: want canceled and no more, have %v and %v", cqe.Errno(), cqe.More())
+			}
+		case remove:
+			if _, err := cqe.Result(); err != nil {
+				t.Fatalf("PrepPollRemove: %v", err)
+			}
+		}
+		cqe.Seen()
+	}
+	if want, have := 0, ring.InFlight(); want != have {
+		t.Fatalf("InFlight: want %d, have %d", want, have)
+	}
+
+	sqe, err = ring.GetEmptySQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqe.PrepPollRemove(poll)
+	ring.Submit()
+	cqe, err := ring.WaitCQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cqe.Seen()
+	if want, have := syscall.ENOENT, cqe.Errno(); want != have {
+		t.Fatalf("PrepPollRemove of a removed poll: want %v, have %v", want, have)
+	}
+}
@@ -0,0 +1,145 @@
+package goliburing
+
+/*
+#include <sys/socket.h>
+#include "liburing.h"
+*/
+import "C"
+import (
+	"syscall"
+	"unsafe"
+)
+
+// sqeResult What the kernel writes into an SQE for its completion, besides the result.
+type sqeResult int
+
+const (
+	// sqeResultNone Nothing but the result.
+	sqeResultNone sqeResult = iota
+	// sqeResultAccept The address of the accepted peer.
+	sqeResultAccept
+	// sqeResultRecvMsg The source address, the length of the ancillary data and the flags of a recvmsg.
+	sqeResultRecvMsg
+)
+
+// PrepAccept Prepare an accept4(2) of a connection on the listening socket fd, with flags
+// such as syscall.SOCK_NONBLOCK and syscall.SOCK_CLOEXEC. Completes with the fd of the new connection,
+// and the address of the peer available through `CQE.Sockaddr`.
+func (s *SQE) PrepAccept(fd int, flags int) {
+	s.wrapped = true
+	s.sqe.addrlen = C.socklen_t(unsafe.Sizeof(s.sqe.addr))
+	C.io_uring_prep_accept(s.sqe.sqe,
+		C.int(fd),
+		(*C.struct_sockaddr)(unsafe.Pointer(&s.sqe.addr)),
+		&s.sqe.addrlen,
+		C.int(flags))
+	s.result = sqeResultAccept
+}
+
+// PrepConnect Prepare a connect(2) of the socket fd to sa, which is copied into the SQE.
+// Error will be syscall.EAFNOSUPPORT if sa is not a `syscall.SockaddrInet4`, `syscall.SockaddrInet6`
+// or `syscall.SockaddrUnix`, or syscall.EINVAL if it is invalid.
+func (s *SQE) PrepConnect(fd int, sa syscall.Sockaddr) error {
+	length, err := putSockaddr(&s.sqe.addr, sa)
+	if err != nil {
+		return err
+	}
+	s.wrapped = true
+
+	C.io_uring_prep_connect(s.sqe.sqe,
+		C.int(fd),
+		(*C.struct_sockaddr)(unsafe.Pointer(&s.sqe.addr)),
+		length)
+	return nil
+}
+
+// PrepSend Prepare a send(2) of data on the socket fd, with flags such as syscall.MSG_NOSIGNAL.
+// data is pinned until the completion, and must not be modified before it.
+func (s *SQE) PrepSend(fd int, data []byte, flags int) {
+	s.pin(data)
+	C.io_uring_prep_send(s.sqe.sqe, C.int(fd), bufferPointer(data), C.size_t(len(data)), C.int(flags))
+}
+
+// PrepRecv Prepare a recv(2) of up to len(data) bytes into data from the socket fd.
+// data is pinned until the completion.
+func (s *SQE) PrepRecv(fd int, data []byte, flags int) {
+	s.pin(data)
+	C.io_uring_prep_recv(s.sqe.sqe, C.int(fd), bufferPointer(data), C.size_t(len(data)), C.int(flags))
+}
+
+// PrepSendMsg Prepare a sendmsg(2) of p with the ancillary data oob on the socket fd, to the address to,
+// or to the connected peer if to is nil, like `syscall.SendmsgN`. The message and the address are copied
+// into the SQE, and p and oob are pinned until the completion, and must not be modified before it.
+// Error will be syscall.EAFNOSUPPORT or syscall.EINVAL if to is not supported or invalid,
+// or of type `ErrGetSQE` if the iovec could not be allocated.
+func (s *SQE) PrepSendMsg(fd int, p []byte, oob []byte, to syscall.Sockaddr, flags int) error {
+	var length C.socklen_t
+	if to != nil {
+		var err error
+		if length, err = putSockaddr(&s.sqe.addr, to); err != nil {
+			return err
+		}
+	}
+	if err := s.prepMsg(p, oob, length); err != nil {
+		return err
+	}
+
+	C.io_uring_prep_sendmsg(s.sqe.sqe, C.int(fd), &s.sqe.msg, C.uint(flags))
+	return nil
+}
+
+// PrepRecvMsg Prepare a recvmsg(2) into p and oob from the socket fd, like `syscall.Recvmsg`.
+// Completes with the number of bytes received, with the source address, the length of the ancillary data
+// and the flags available through `CQE.Sockaddr`, `CQE.OOBN` and `CQE.RecvFlags`.
+// p and oob are pinned until the completion.
+// Error will be of type `ErrGetSQE` if the iovec could not be allocated.
+func (s *SQE) PrepRecvMsg(fd int, p []byte, oob []byte, flags int) error {
+	if err := s.prepMsg(p, oob, C.socklen_t(unsafe.Sizeof(s.sqe.addr))); err != nil {
+		return err
+	}
+
+	C.io_uring_prep_recvmsg(s.sqe.sqe, C.int(fd), &s.sqe.msg, C.uint(flags))
+	s.result = sqeResultRecvMsg
+	return nil
+}
+
+// prepMsg Sets up the message header of the SQE for p, oob and an address of nameLength bytes.
+func (s *SQE) prepMsg(p []byte, oob []byte, nameLength C.socklen_t) error {
+	// Pin the buffers before their addresses are written into the SQE.
+	s.pin(p, oob)
+	if err := s.setIOV([][]byte{p}); err != nil {
+		return err
+	}
+
+	msg := &s.sqe.msg
+	*msg = C.struct_msghdr{}
+	if nameLength > 0 {
+		msg.msg_name = unsafe.Pointer(&s.sqe.addr)
+		msg.msg_namelen = nameLength
+	}
+	msg.msg_iov = s.sqe.iov
+	msg.msg_iovlen = 1
+	if len(oob) > 0 {
+		msg.msg_control = bufferPointer(oob)
+		msg.msg_controllen = C.size_t(len(oob))
+	}
+	return nil
+}
+
+// PrepShutdown Prepare a shutdown(2) of the socket fd, with how one of syscall.SHUT_RD,
+// syscall.SHUT_WR or syscall.SHUT_RDWR.
+func (s *SQE) PrepShutdown(fd int, how int) {
+	C.io_uring_prep_shutdown(s.sqe.sqe, C.int(fd), C.int(how))
+}
+
+// decodeResult Copies what the kernel wrote into the SQE for a successful completion into c.
+func (s *SQE) decodeResult(c *CQE) {
+	switch s.result {
+	case sqeResultAccept:
+		c.sockaddr = getSockaddr(&s.sqe.addr, s.sqe.addrlen)
+	case sqeResultRecvMsg:
+		c.sockaddr = getSockaddr(&s.sqe.addr, s.sqe.msg.msg_namelen)
+		c.oobn = int(s.sqe.msg.msg_controllen)
+		c.recvFlags = int(s.sqe.msg.msg_flags)
+	}
+}
@@ -0,0 +1,274 @@
+package goliburing
+
+import (
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+// waitResults Waits for the completions of userData in any order and gets them by user data.
+func waitResults(t *testing.T, ring *Ring, userData ...uint64) map[uint64]CQE {
+	cqes := make(map[uint64]CQE)
+	for range userData {
+		cqe, err := ring.WaitCQE()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := cqe.Result(); err != nil {
+			t.Fatalf("completion of %d: %v", cqe.UserData(), err)
+		}
+		cqes[cqe.UserData()] = *cqe
+		cqe.Seen()
+	}
+
+	return cqes
+}
+
+func socket(t *testing.T, domain, typ int, sa syscall.Sockaddr) int {
+	fd, err := syscall.Socket(domain, typ|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		t.Skip(err)
+	}
+	if sa != nil {
+		if err := syscall.Bind(fd, sa); err != nil {
+			syscall.Close(fd)
+			t.Skip(err)
+		}
+	}
+
+	return fd
+}
+
+func getsockname(t *testing.T, fd int) syscall.Sockaddr {
+	sa, err := syscall.Getsockname(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return sa
+}
+
+func TestPrepAcceptConnect(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	listener := socket(t, syscall.AF_INET, syscall.SOCK_STREAM, &syscall.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}})
+	defer syscall.Close(listener)
+	if err := syscall.Listen(listener, 1); err != nil {
+		t.Fatal(err)
+	}
+	client := socket(t, syscall.AF_INET, syscall.SOCK_STREAM, nil)
+	defer syscall.Close(client)
+
+	accept := prepSubmit(t, ring, func(sqe *SQE) error {
+		sqe.PrepAccept(listener, syscall.SOCK_CLOEXEC)
+		return nil
+	})
+	connect := prepSubmit(t, ring, func(sqe *SQE) error {
+		return sqe.PrepConnect(client, getsockname(t, listener))
+	})
+	cqes := waitResults(t, ring, accept, connect)
+
+	acceptCQE := cqes[accept]
+	server, _ := acceptCQE.Result()
+	defer syscall.Close(server)
+	if want, have := getsockname(t, client), acceptCQE.Sockaddr(); !reflect.DeepEqual(want, have) {
+		t.Fatalf("Sockaddr: want %+v, have %+v", want, have)
+	}
+
+	send := prepSubmit(t, ring, func(sqe *SQE) error {
+		sqe.PrepSend(client, []byte("hello"), syscall.MSG_NOSIGNAL)
+		return nil
+	})
+	data := make([]byte, 16)
+	recv := prepSubmit(t, ring, func(sqe *SQE) error {
+		sqe.PrepRecv(server, data, 0)
+		return nil
+	})
+	cqes = waitResults(t, ring, send, recv)
+	recvCQE := cqes[recv]
+	n, _ := recvCQE.Result()
+	if want, have := "hello", string(data[:n]); want != have {
+		t.Fatalf("PrepRecv: want %q, have %q", want, have)
+	}
+
+	// After a shutdown of the client's side, the server reads the end of the stream.
+	shutdown := prepSubmit(t, ring, func(sqe *SQE) error {
+		sqe.PrepShutdown(client, syscall.SHUT_WR)
+		return nil
+	})
+	waitResults(t, ring, shutdown)
+	recv = prepSubmit(t, ring, func(sqe *SQE) error {
+		sqe.PrepRecv(server, data, 0)
+		return nil
+	})
+	cqes = waitResults(t, ring, recv)
+	recvCQE = cqes[recv]
+	if n, _ := recvCQE.Result(); n != 0 {
+		t.Fatalf("PrepRecv after PrepShutdown: want 0, have %d", n)
+	}
+}
+
+func TestPrepSendMsgRecvMsg(t *testing.T) {
+	unixPath := "/tmp/sqe-socket-test.sock"
+	os.Remove(unixPath)
+	defer os.Remove(unixPath)
+
+	tests := []struct {
+		name   string
+		domain int
+		addr   syscall.Sockaddr
+	}{
+		{"inet4", syscall.AF_INET, &syscall.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}}},
+		{"inet6", syscall.AF_INET6, &syscall.SockaddrInet6{Addr: [16]byte{15: 1}}},
+		{"unix", syscall.AF_UNIX, &syscall.SockaddrUnix{Name: unixPath}},
+		{"unix abstract", syscall.AF_UNIX, &syscall.SockaddrUnix{Name: "@sqe-socket-test"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ring, err := NewRing(8, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ring.Destroy()
+
+			receiver := socket(t, test.domain, syscall.SOCK_DGRAM, test.addr)
+			defer syscall.Close(receiver)
+			// Bind the sender too, so it has an address to report.
+			senderAddr := test.addr
+			if unix, ok := test.addr.(*syscall.SockaddrUnix); ok {
+				senderAddr = &syscall.SockaddrUnix{Name: unix.Name + "-sender"}
+				if unix.Name[0] != '@' {
+					defer os.Remove(unix.Name + "-sender")
+				}
+			}
+			sender := socket(t, test.domain, syscall.SOCK_DGRAM, senderAddr)
+			defer syscall.Close(sender)
+
+			data := make([]byte, 16)
+			recv := prepSubmit(t, ring, func(sqe *SQE) error {
+				return sqe.PrepRecvMsg(receiver, data, nil, 0)
+			})
+			send := prepSubmit(t, ring, func(sqe *SQE) error {
+				return sqe.PrepSendMsg(sender, []byte("hello"), nil, getsockname(t, receiver), 0)
+			})
+			cqes := waitResults(t, ring, recv, send)
+
+			recvCQE := cqes[recv]
+			n, _ := recvCQE.Result()
+			if want, have := "hello", string(data[:n]); want != have {
+				t.Fatalf("PrepRecvMsg: want %q, have %q", want, have)
+			}
+			if want, have := getsockname(t, sender), recvCQE.Sockaddr(); !reflect.DeepEqual(want, have) {
+				t.Fatalf("Sockaddr: want %+v, have %+v", want, have)
+			}
+		})
+	}
+}
+
+func TestPrepSendMsgRights(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Close(fds[0])
+	defer syscall.Close(fds[1])
+
+	f, err := os.Open("/dev/null")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	send := prepSubmit(t, ring, func(sqe *SQE) error {
+		return sqe.PrepSendMsg(fds[0], []byte("fd"), syscall.UnixRights(int(f.Fd())), nil, 0)
+	})
+	data := make([]byte, 16)
+	oob := make([]byte, syscall.CmsgSpace(4))
+	recv := prepSubmit(t, ring, func(sqe *SQE) error {
+		return sqe.PrepRecvMsg(fds[1], data, oob, syscall.MSG_CMSG_CLOEXEC)
+	})
+	cqes := waitResults(t, ring, send, recv)
+
+	recvCQE := cqes[recv]
+	if recvCQE.OOBN() == 0 || recvCQE.RecvFlags()&syscall.MSG_CTRUNC != 0 {
+		t.Fatalf("PrepRecvMsg: want ancillary data, have %d bytes and flags %#x", recvCQE.OOBN(), recvCQE.RecvFlags())
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:recvCQE.OOBN()])
+	if err != nil {
+		t.Fatal(err)
+	}
+	rights, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 1, len(rights); want != have {
+		t.Fatalf("rights: want %d fds, have %d", want, have)
+	}
+	syscall.Close(rights[0])
+}
+
+func TestPrepConnectUnsupported(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	sqe, err := ring.GetEmptySQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqe.prepNop()
+
+	if err := sqe.PrepConnect(0, &syscall.SockaddrNetlink{}); err != syscall.EAFNOSUPPORT {
+		t.Fatalf("PrepConnect: want %v, have %v", syscall.EAFNOSUPPORT, err)
+	}
+	if err := sqe.PrepConnect(0, &syscall.SockaddrInet4{Port: 1 << 16}); err != syscall.EINVAL {
+		t.Fatalf("PrepConnect: want %v, have %v", syscall.EINVAL, err)
+	}
+}
+
+func TestPrepAcceptRecvMsgSkipSuccess(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	// Both write into the SQE at completion, so it must not be handed back before.
+	preps := map[string]func(sqe *SQE) error{
+		"accept": func(sqe *SQE) error {
+			sqe.PrepAccept(-1, 0)
+			return nil
+		},
+		"recvmsg": func(sqe *SQE) error {
+			return sqe.PrepRecvMsg(-1, make([]byte, 16), nil, 0)
+		},
+	}
+	for name, prep := range preps {
+		sqe, err := ring.GetEmptySQE()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := prep(sqe); err != nil {
+			t.Fatal(err)
+		}
+		if e, ok := sqe.SetCQESkipSuccess().(*ErrGetSQE); !ok || e.Code != ErrSQESkipSuccess {
+			t.Fatalf("SetCQESkipSuccess on %s: want ErrSQESkipSuccess, have %v", name, e)
+		}
+		if err := sqe.Discard(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
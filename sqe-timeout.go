@@ -0,0 +1,68 @@
+package goliburing
+
+/*
+#include "liburing.h"
+*/
+import "C"
+import (
+	"time"
+)
+
+// PrepTimeout Prepare a timeout that expires after d, completing with ETIME, or completes with 0
+// as soon as count other completions were posted, whichever comes first. A count of 0 only waits for d.
+// See `TimeoutFlag` for absolute times, the clock and multishot timeouts. With `IORingTimeoutAbs`,
+// d is the time on the clock since its epoch, see `PrepTimeoutAt` for wall clock times.
+// The timeout is stored in the SQE, so it stays valid until the timeout completes.
+func (s *SQE) PrepTimeout(d time.Duration, count uint32, flags TimeoutFlag) {
+	C.io_uring_prep_timeout(s.sqe.sqe, s.timespec(d), C.uint(count), C.uint(flags))
+}
+
+// PrepTimeoutAt Prepare a timeout that expires at t on the wall clock, see `PrepTimeout`.
+func (s *SQE) PrepTimeoutAt(t time.Time, count uint32) {
+	s.PrepTimeout(time.Duration(t.UnixNano()), count, IORingTimeoutAbs|IORingTimeoutRealtime)
+}
+
+// PrepTimeoutRemove Prepare the removal of the timeout submitted with userData, see `SQE.UserData`.
+// The timeout then completes with ECANCELED, and the removal itself completes with 0,
+// with ENOENT if no such timeout was in flight, or with EBUSY if it is already expiring.
+func (s *SQE) PrepTimeoutRemove(userData uint64) {
+	C.io_uring_prep_timeout_remove(s.sqe.sqe, C.__u64(userData), 0)
+}
+
+// PrepTimeoutUpdate Prepare a change of the timeout submitted with userData to expire after d instead,
+// see `PrepTimeout` for d and flags. Completes with 0, or with ENOENT if no such timeout was in flight.
+func (s *SQE) PrepTimeoutUpdate(userData uint64, d time.Duration, flags TimeoutFlag) {
+	C.io_uring_prep_timeout_update(s.sqe.sqe, s.timespec(d), C.__u64(userData), C.uint(flags))
+}
+
+// LinkTimeout Bound the operation prepared on this SQE with a timeout of d, see `PrepTimeout` for flags.
+// The timeout is linked to the operation, so it must be called after the `Prep` method, while this SQE is
+// the last one taken from the ring and not submitted yet. The timeout is made ready together with this SQE,
+// and discarded with it, see `Discard`, so the operation and its timeout always go out next to each other.
+// Returns the SQE of the timeout, whose completion tells the outcome: ETIME if the timeout expired,
+// see `CQE.TimedOut`, in which case the operation completes with ECANCELED, or ECANCELED if the operation
+// completed first. Error will be of type `ErrGetSQE`, with code `ErrSQEInFlight` if this SQE was
+// already submitted, or `ErrSQENotLast` if another SQE was taken from the ring since.
+func (s *SQE) LinkTimeout(d time.Duration, flags TimeoutFlag) (*SQE, error) {
+	r := s.ring
+	r.sqeMu.Lock()
+	defer r.sqeMu.Unlock()
+
+	if s.state != sqePending {
+		return nil, NewErrGetSQE(ErrSQEInFlight)
+	}
+	if r.pending[len(r.pending)-1] != s {
+		return nil, NewErrGetSQE(ErrSQENotLast)
+	}
+
+	timeout, err := r.getEmptySQE(s.readyOnSubmit)
+	if err != nil {
+		return nil, err
+	}
+	s.linked = timeout
+	timeout.ready = s.ready
+
+	s.SetLink()
+	C.io_uring_prep_link_timeout(timeout.sqe.sqe, timeout.timespec(d), C.uint(flags))
+	return timeout, nil
+}
@@ -0,0 +1,266 @@
+package goliburing
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// waitErrno Waits for a completion and gets its user data and errno.
+func waitErrno(t *testing.T, ring *Ring) (uint64, syscall.Errno) {
+	cqe, err := ring.WaitCQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cqe.Seen()
+
+	return cqe.UserData(), cqe.Errno()
+}
+
+func TestPrepTimeout(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	const timeout = 20 * time.Millisecond
+	preps := map[string]func(sqe *SQE) error{
+		"relative": func(sqe *SQE) error {
+			sqe.PrepTimeout(timeout, 0, 0)
+			return nil
+		},
+		"boottime": func(sqe *SQE) error {
+			sqe.PrepTimeout(timeout, 0, IORingTimeoutBoottime)
+			return nil
+		},
+		"absolute": func(sqe *SQE) error {
+			sqe.PrepTimeoutAt(time.Now().Add(timeout), 0)
+			return nil
+		},
+	}
+	for name, prep := range preps {
+		start := time.Now()
+		prepSubmit(t, ring, prep)
+		if _, errno := waitErrno(t, ring); errno != syscall.ETIME {
+			t.Fatalf("%s: want %v, have %v", name, syscall.ETIME, errno)
+		}
+		if elapsed := time.Since(start); elapsed < timeout {
+			t.Fatalf("%s: expired after %v, want at least %v", name, elapsed, timeout)
+		}
+	}
+}
+
+func TestPrepTimeoutCount(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	timeout := prepSubmit(t, ring, func(sqe *SQE) error {
+		sqe.PrepTimeout(time.Minute, 1, 0)
+		return nil
+	})
+	prepSubmit(t, ring, func(sqe *SQE) error {
+		sqe.PrepRead(-1, make([]byte, 16), 0)
+		return nil
+	})
+
+	for i := 0; i < 2; i++ {
+		if userData, errno := waitErrno(t, ring); userData == timeout && errno != 0 {
+			t.Fatalf("timeout with count: want 0, have %v", errno)
+		}
+	}
+}
+
+func TestPrepTimeoutRemove(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	timeout := prepSubmit(t, ring, func(sqe *SQE) error {
+		sqe.PrepTimeout(time.Minute, 0, 0)
+		return nil
+	})
+	remove := prepSubmit(t, ring, func(sqe *SQE) error {
+		sqe.PrepTimeoutRemove(timeout)
+		return nil
+	})
+
+	for i := 0; i < 2; i++ {
+		userData, errno := waitErrno(t, ring)
+		switch {
+		case userData == timeout && errno != syscall.ECANCELED:
+			t.Fatalf("removed timeout: want %v, have %v", syscall.ECANCELED, errno)
+		case userData == remove && errno != 0:
+			t.Fatalf("PrepTimeoutRemove: %v", errno)
+		}
+	}
+	if want, have := 0, ring.InFlight(); want != have {
+		t.Fatalf("InFlight: want %d, have %d", want, have)
+	}
+}
+
+func TestPrepTimeoutUpdate(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	start := time.Now()
+	timeout := prepSubmit(t, ring, func(sqe *SQE) error {
+		sqe.PrepTimeout(time.Minute, 0, 0)
+		return nil
+	})
+	update := prepSubmit(t, ring, func(sqe *SQE) error {
+		sqe.PrepTimeoutUpdate(timeout, time.Millisecond, 0)
+		return nil
+	})
+
+	for i := 0; i < 2; i++ {
+		userData, errno := waitErrno(t, ring)
+		switch {
+		case userData == timeout && errno != syscall.ETIME:
+			t.Fatalf("updated timeout: want %v, have %v", syscall.ETIME, errno)
+		case userData == update && errno != 0:
+			t.Fatalf("PrepTimeoutUpdate: %v", errno)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("updated timeout expired after %v", elapsed)
+	}
+}
+
+func TestPrepTimeoutMultishot(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	const count = 3
+	prepSubmit(t, ring, func(sqe *SQE) error {
+		sqe.PrepTimeout(time.Millisecond, count, IORingTimeoutMultishot)
+		return nil
+	})
+
+	for i := 0; i < count; i++ {
+		cqe, err := ring.WaitCQE()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cqe.Errno() == syscall.EINVAL {
+			t.Skip("multishot timeouts not supported")
+		}
+		if want, have := i < count-1, cqe.More(); want != have {
+			t.Fatalf("expiration %d: want more %v, have %v", i, want, have)
+		}
+		if want, have := syscall.ETIME, cqe.Errno(); want != have {
+			t.Fatalf("expiration %d: want %v, have %v", i, want, have)
+		}
+		cqe.Seen()
+	}
+	if want, have := 0, ring.InFlight(); want != have {
+		t.Fatalf("InFlight: want %d, have %d", want, have)
+	}
+}
+
+func TestLinkTimeout(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	for _, ready := range []bool{false, true} {
+		if ready {
+			if _, err := w.Write([]byte("ready")); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		sqe, err := ring.GetEmptySQE()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sqe.PrepRead(int(r.Fd()), make([]byte, 16), 0)
+		read := sqe.UserData()
+		timeoutSQE, err := sqe.LinkTimeout(20*time.Millisecond, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		timeout := timeoutSQE.UserData()
+		ring.Submit()
+
+		for i := 0; i < 2; i++ {
+			cqe, err := ring.WaitCQE()
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch cqe.UserData() {
+			case read:
+				// A read of an empty pipe is cut short by the timeout.
+				if want, have := !ready, cqe.Canceled(); want != have {
+					t.Fatalf("ready %v, read: want canceled %v, have %v", ready, want, cqe.Errno())
+				}
+			case timeout:
+				if want, have := !ready, cqe.TimedOut(); want != have {
+					t.Fatalf("ready %v, timeout: want timed out %v, have %v", ready, want, cqe.Errno())
+				}
+			}
+			cqe.Seen()
+		}
+	}
+}
+
+func TestLinkTimeoutNotLast(t *testing.T) {
+	ring, err := NewRing(8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ring.Destroy()
+
+	sqe, err := ring.GetEmptySQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqe.prepNop()
+	other, err := ring.GetEmptySQE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.prepNop()
+
+	// The timeout would end up linked to other instead.
+	_, err = sqe.LinkTimeout(time.Second, 0)
+	if e, ok := err.(*ErrGetSQE); !ok || e.Code != ErrSQENotLast {
+		t.Fatalf("LinkTimeout: want ErrSQENotLast, have %v", err)
+	}
+
+	if _, err := ring.Submit(); err != nil {
+		t.Fatal(err)
+	}
+	_, err = other.LinkTimeout(time.Second, 0)
+	if e, ok := err.(*ErrGetSQE); !ok || e.Code != ErrSQEInFlight {
+		t.Fatalf("LinkTimeout: want ErrSQEInFlight, have %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		cqe, err := ring.WaitCQE()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cqe.Seen()
+	}
+}
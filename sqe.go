@@ -3,12 +3,20 @@ package goliburing
 /*
 #include <stdlib.h>
 #include <string.h>
+#include <sys/socket.h>
 #include <sys/uio.h>
 #include "liburing.h"
 
 struct sqe {
 	off_t offset;
 	struct iovec *iov;
+	unsigned iov_cap;
+	struct __kernel_timespec ts;
+	struct sockaddr_storage addr;
+	socklen_t addrlen;
+	struct msghdr msg;
+	// The entry is prepared here, and only copied into the submission queue once it is ready.
+	struct io_uring_sqe staged;
 	struct io_uring_sqe *sqe;
 };
 
@@ -17,7 +25,8 @@ struct sqe_create_result {
 	int ret;
 };
 
-struct sqe_create_result get_sqe(struct io_uring *ring) {
+// Allocates an sqe wrapper with room for a single iovec.
+struct sqe_create_result create_sqe() {
 	struct sqe_create_result res;
 	res.s = malloc(sizeof(*res.s));
 	if(!res.s) {
@@ -31,31 +40,115 @@ struct sqe_create_result get_sqe(struct io_uring *ring) {
 		free(res.s);
 		return res;
 	}
-
-	// res.s->sqe = io_uring_get_sqe(ring);
-	// if(res.s->sqe == NULL) {
-	// 	free(res.s);
-	// 	res.ret = 2;
-	// 	return res;
-	// }
+	res.s->iov_cap = 1;
+	res.s->sqe = &res.s->staged;
 
 	res.ret = 0;
 	return res;
 }
+
+void destroy_sqe(struct sqe *s) {
+	if (s != NULL) {
+		free(s->iov);
+		free(s);
+	}
+}
+
+// Makes sure the sqe has room for at least n iovecs.
+// Returns 1 on a malloc failure.
+int reserve_iov(struct sqe *s, unsigned n) {
+	if (n <= s->iov_cap) {
+		return 0;
+	}
+
+	struct iovec *iov = realloc(s->iov, n * sizeof(*iov));
+	if (!iov) {
+		return 1;
+	}
+
+	s->iov = iov;
+	s->iov_cap = n;
+	return 0;
+}
+
+void set_buf_index(struct io_uring_sqe *sqe, int buf_index) {
+	sqe->buf_index = buf_index;
+}
+
+void set_buf_group(struct io_uring_sqe *sqe, __u16 buf_group) {
+	sqe->buf_group = buf_group;
+}
+
+void set_iov(struct sqe *s, unsigned i, void *base, size_t len) {
+	s->iov[i].iov_base = base;
+	s->iov[i].iov_len = len;
+}
+
+// Stores nsec in the sqe, so the kernel can read it for as long as the sqe is in flight.
+struct __kernel_timespec *set_timespec(struct sqe *s, long long nsec) {
+	s->ts.tv_sec = nsec / 1000000000;
+	s->ts.tv_nsec = nsec % 1000000000;
+	return &s->ts;
+}
+
+void prep_sync_file_range(struct io_uring_sqe *sqe, int fd, unsigned len, __u64 offset, int flags) {
+	io_uring_prep_rw(IORING_OP_SYNC_FILE_RANGE, sqe, fd, NULL, len, offset);
+	sqe->sync_range_flags = flags;
+}
 */
 import "C"
 import (
+	"runtime"
+	"time"
 	"unsafe"
 )
 
+// sqeState Tracks where an SQE is in its lifetime.
+type sqeState int
+
+const (
+	// sqeFree The SQE is pooled by the ring and may be handed out.
+	sqeFree sqeState = iota
+	// sqePending The SQE was handed out and has not been copied into the submission queue yet.
+	sqePending
+	// sqeInFlight The SQE was copied into the submission queue and its completion has not been reaped yet.
+	sqeInFlight
+)
+
 // SQE Represents a submission queue entry.
+// An SQE is handed out by `Ring.GetEmptySQE` or `Ring.PrepareSQE`, and must then be either submitted
+// or discarded, see `Discard`. It is prepared in memory of its own, and only copied into the submission
+// queue once it is ready, so a submission from another goroutine never sends a half prepared SQE.
+// Once submitted, it stays in flight, together with any iovecs and data it references, until its completion
+// is reaped. It then goes back to the ring to be reused, so it must not be used after its completion was reaped.
 type SQE struct {
-	sqe  *C.struct_sqe
-	ring *Ring
+	sqe   *C.struct_sqe
+	ring  *Ring
+	token uint64
+	data  interface{}
+	state sqeState
+	// ready Set once the SQE is prepared, only ready SQEs are copied into the submission queue.
+	ready bool
+	// readyOnSubmit Set for the SQEs of `Ring.GetEmptySQE`, which `Ring.Submit` and friends make ready.
+	readyOnSubmit bool
+	// linked The timeout linked to the SQE, see `LinkTimeout`, made ready and discarded together with it.
+	linked *SQE
+	// result What the kernel writes into the SQE for the completion, see `CQE.load`.
+	result sqeResult
+	// pinner Pins the Go buffers the kernel uses until the completion, since their addresses are
+	// stored in C memory, the kernel SQE and its iovecs, after the cgo calls that hand them over return.
+	pinner runtime.Pinner
+	// pinned Set when the pinner holds buffers, see `pin`.
+	pinned bool
+	// wrapped Set when the kernel uses memory of the SQE itself, such as its iovecs, timespec or socket address.
+	wrapped bool
+	// claimed Set by `Ring.getClaimedSQEs` when the completion is reaped by the ring itself or a file reader or writer,
+	// which keeps it from being handed out by `Ring.WaitCQE` and friends.
+	claimed bool
 }
 
 func newSQE(ring *Ring) (*SQE, error) {
-	res := C.get_sqe(ring.ring)
+	res := C.create_sqe()
 	if int(res.ret) != 0 {
 		return nil, NewErrGetSQE(ErrGetSQECode(res.ret))
 	}
@@ -66,28 +159,291 @@ func newSQE(ring *Ring) (*SQE, error) {
 	}, nil
 }
 
-func (s *SQE) init() error {
-	s.sqe.sqe = C.io_uring_get_sqe(s.ring.ring)
-	if unsafe.Pointer(s.sqe.sqe) == unsafe.Pointer(C.NULL) {
-		return NewErrGetSQE(ErrSQEFull)
+func (s *SQE) destroy() {
+	C.destroy_sqe(s.sqe)
+	s.sqe = nil
+}
+
+// init Clears a free SQE and registers a fresh token for it, r.sqeMu must be held.
+// Nothing of a previous operation is left in the entry, since it is cleared as well.
+func (s *SQE) init(readyOnSubmit bool) {
+	*s.sqe.sqe = C.struct_io_uring_sqe{}
+	s.state = sqePending
+	s.readyOnSubmit = readyOnSubmit
+	s.token = s.ring.sqes.register(s)
+}
+
+// reset Clears what the SQE kept for its last operation, r.sqeMu must be held.
+func (s *SQE) reset() {
+	s.state = sqeFree
+	s.ready = false
+	s.readyOnSubmit = false
+	s.linked = nil
+	s.data = nil
+	s.result = sqeResultNone
+	s.unpin()
+	s.wrapped = false
+	s.claimed = false
+}
+
+// markReady Makes the SQE, and the timeout linked to it, ready to go out, r.sqeMu must be held.
+func (s *SQE) markReady() {
+	s.ready = true
+	if s.linked != nil {
+		s.linked.ready = true
 	}
+}
+
+// stamp Sets the token as the user data of the kernel SQE.
+func (s *SQE) stamp() {
+	C.io_uring_sqe_set_data64(s.sqe.sqe, C.__u64(s.token))
+}
+
+// Discard Hands back an SQE that will not be submitted, such as after a `Prep` method failed.
+// Since the SQE was not copied into the submission queue yet, it goes back to the ring straight away,
+// together with the timeout linked to it, see `LinkTimeout`, and must not be used afterwards.
+// Error will be of type `ErrGetSQE` with code `ErrSQEInFlight` if the SQE was already submitted.
+func (s *SQE) Discard() error {
+	r := s.ring
+	r.sqeMu.Lock()
+	defer r.sqeMu.Unlock()
+
+	if s.state != sqePending {
+		return NewErrGetSQE(ErrSQEInFlight)
+	}
+
+	if s.linked != nil {
+		r.dropPending(s.linked)
+	}
+	r.dropPending(s)
 	return nil
 }
 
-// PrepWriteV Prepare a vectored write.
-func (s *SQE) PrepWriteV(fd int, data []byte, offset uint64) {
+// referencesMemory Reports whether the kernel uses memory held by the SQE until the completion.
+func (s *SQE) referencesMemory() bool {
+	return s.wrapped || s.pinned || s.result != sqeResultNone
+}
+
+// UserData Gets the user data the completion of this SQE will carry.
+// Use it to refer to this SQE from other operations, such as cancellations.
+func (s *SQE) UserData() uint64 {
+	return s.token
+}
+
+// PrepWriteV Prepare a vectored write of bufs in order.
+// Since `net.Buffers` is a [][]byte it can be passed as is.
+// Error will be of type `ErrGetSQE` if the iovecs could not be allocated.
+func (s *SQE) PrepWriteV(fd int, bufs [][]byte, offset uint64) error {
+	return s.prepRWV(IORingOpWriteV, fd, bufs, offset)
+}
+
+// PrepReadFixed Prepare a read of up to length bytes into the start of the registered buffer
+// at bufIndex. See `Ring.RegisterBuffers`. Panics if bufIndex or length is out of range.
+func (s *SQE) PrepReadFixed(fd int, bufIndex int, length int, offset uint64) {
+	s.prepRWFixed(IORingOpReadFixed, fd, bufIndex, length, offset)
+}
+
+// PrepWriteFixed Prepare a write of the first length bytes of the registered buffer
+// at bufIndex. See `Ring.RegisterBuffers`. Panics if bufIndex or length is out of range.
+func (s *SQE) PrepWriteFixed(fd int, bufIndex int, length int, offset uint64) {
+	s.prepRWFixed(IORingOpWriteFixed, fd, bufIndex, length, offset)
+}
+
+func (s *SQE) prepRWFixed(op OpFlag, fd int, bufIndex int, length int, offset uint64) {
+	iov := s.ring.bufferIOV(bufIndex)
+	if length < 0 || length > int(iov.iov_len) {
+		panic("goliburing: registered buffer length out of range")
+	}
 	s.sqe.offset = C.off_t(offset)
-	s.sqe.iov.iov_len = C.size_t(len(data))
-	s.sqe.iov.iov_base = unsafe.Pointer(&data[0])
 
-	C.io_uring_prep_rw(IORingOpWriteV, s.sqe.sqe,
+	C.io_uring_prep_rw(C.int(op), s.sqe.sqe,
+		C.int(fd),
+		iov.iov_base,
+		C.uint(length),
+		C.ulonglong(offset))
+	C.set_buf_index(s.sqe.sqe, C.int(bufIndex))
+}
+
+// SetFixedFile Targets the slot of the ring's fixed file table given as fd to the `Prep` method,
+// instead of a raw fd. See `Ring.RegisterFiles`. Must be called after the `Prep` method.
+func (s *SQE) SetFixedFile() {
+	s.setFlags(IOSQEFixedFile)
+}
+
+// PrepFsync Prepare an fsync(2) of fd, or an fdatasync(2) if datasyncOnly is set.
+// The fsync is not ordered with other SQEs in flight, link it to the writes it must follow,
+// see `SetLink`, or make it a barrier, see `SetDrain`.
+func (s *SQE) PrepFsync(fd int, datasyncOnly bool) {
+	var flags C.uint
+	if datasyncOnly {
+		flags = C.IORING_FSYNC_DATASYNC
+	}
+
+	C.io_uring_prep_fsync(s.sqe.sqe, C.int(fd), flags)
+}
+
+// PrepSyncFileRange Prepare a sync_file_range(2) of length bytes of fd at offset.
+// A length of 0 syncs up to the end of the file. See `SyncFileRangeFlag`.
+// Like `PrepFsync`, it is not ordered with other SQEs in flight.
+func (s *SQE) PrepSyncFileRange(fd int, offset uint64, length uint32, flags SyncFileRangeFlag) {
+	C.prep_sync_file_range(s.sqe.sqe, C.int(fd), C.uint(length), C.__u64(offset), C.int(flags))
+}
+
+// prepNop Prepare an operation that does nothing.
+func (s *SQE) prepNop() {
+	C.io_uring_prep_nop(s.sqe.sqe)
+}
+
+// SetLink Links this SQE to the next one submitted, which only starts once this one completed successfully.
+// If this one fails or completes short, the next one completes with ECANCELED, see `CQE.Canceled`.
+// Must be called after the `Prep` method.
+func (s *SQE) SetLink() {
+	s.setFlags(IOSQEIOLink)
+}
+
+// SetHardLink Links this SQE to the next one submitted, which only starts once this one completed,
+// whatever its result. Must be called after the `Prep` method.
+func (s *SQE) SetHardLink() {
+	s.setFlags(IOSQEIOHardLink)
+}
+
+// SetDrain Makes this SQE a barrier: it only starts once every SQE submitted before it has completed,
+// and SQEs submitted after it only start once it has completed. Must be called after the `Prep` method.
+func (s *SQE) SetDrain() {
+	s.setFlags(IOSQEIODrain)
+}
+
+// SetAsync Punts this SQE straight to io-wq, skipping the non-blocking attempt.
+// Use it for operations that are known to block. Must be called after the `Prep` method.
+func (s *SQE) SetAsync() {
+	s.setFlags(IOSQEAsync)
+}
+
+// SetBufferSelect Makes the operation pick its buffer from the provided buffer group when it is ready
+// to transfer data. The buffer ID is then carried in the CQE flags. Must be called after the `Prep` method.
+func (s *SQE) SetBufferSelect(group uint16) {
+	s.setFlags(IOSQEBufferSelect)
+	C.set_buf_group(s.sqe.sqe, C.__u16(group))
+}
+
+// SetCQESkipSuccess Suppresses the completion of this SQE if it succeeds, where a short read or write
+// counts as a failure. Since nothing is reaped then, the SQE is handed back to the ring as soon as it is
+// submitted, and the completion of a failure carries no data. Requires `IORingFeatCQESkip`.
+// Must be called after the `Prep` method.
+// Error will be of type `ErrGetSQE` with code `ErrSQESkipSuccess` if the operation uses memory that must
+// outlive it, such as a buffer, iovecs, a timespec or a socket address, since without a completion there
+// is no telling when the kernel is done with it. The flag is then left unset.
+func (s *SQE) SetCQESkipSuccess() error {
+	if s.referencesMemory() {
+		return NewErrGetSQE(ErrSQESkipSuccess)
+	}
+
+	s.setFlags(IOSQECQESkipSuccess)
+	return nil
+}
+
+// skipsSuccess Reports whether the SQE gets no completion on success.
+func (s *SQE) skipsSuccess() bool {
+	return SQEFlag(s.sqe.sqe.flags)&IOSQECQESkipSuccess != 0
+}
+
+func (s *SQE) setFlags(flags SQEFlag) {
+	s.sqe.sqe.flags |= C.__u8(flags)
+}
+
+// SetData Associates a Go value with the submission queue event.
+// The value is kept by the ring until the completion is reaped,
+// and is then available through `CQE.Data`.
+// Returns the user data of the submission.
+func (s *SQE) SetData(v interface{}) uint64 {
+	s.data = v
+	return s.token
+}
+
+// PrepRead Prepare a read of up to len(data) bytes into data.
+// data is pinned until the completion is reaped.
+func (s *SQE) PrepRead(fd int, data []byte, offset uint64) {
+	s.pin(data)
+	s.sqe.offset = C.off_t(offset)
+
+	C.io_uring_prep_rw(IORingOpRead, s.sqe.sqe,
+		C.int(fd),
+		bufferPointer(data),
+		C.uint(len(data)),
+		C.ulonglong(offset))
+}
+
+// PrepReadV Prepare a vectored read, filling bufs in order.
+// Error will be of type `ErrGetSQE` if the iovecs could not be allocated.
+func (s *SQE) PrepReadV(fd int, bufs [][]byte, offset uint64) error {
+	return s.prepRWV(IORingOpReadV, fd, bufs, offset)
+}
+
+// prepRWV Prepare a vectored operation over bufs, growing the iovecs as needed.
+// bufs are pinned until the completion is reaped.
+func (s *SQE) prepRWV(op OpFlag, fd int, bufs [][]byte, offset uint64) error {
+	s.pin(bufs...)
+	if err := s.setIOV(bufs); err != nil {
+		return err
+	}
+
+	s.sqe.offset = C.off_t(offset)
+
+	C.io_uring_prep_rw(C.int(op), s.sqe.sqe,
 		C.int(fd),
 		unsafe.Pointer(s.sqe.iov),
-		C.uint(1),
+		C.uint(len(bufs)),
 		C.ulonglong(offset))
+	return nil
+}
+
+// setIOV Points the iovecs of the SQE at bufs, growing them as needed.
+// Error will be of type `ErrGetSQE` if the iovecs could not be allocated.
+func (s *SQE) setIOV(bufs [][]byte) error {
+	if C.reserve_iov(s.sqe, C.uint(len(bufs))) != 0 {
+		return NewErrGetSQE(ErrSQEMalloc)
+	}
+	s.wrapped = true
+
+	for i, buf := range bufs {
+		C.set_iov(s.sqe, C.uint(i), bufferPointer(buf), C.size_t(len(buf)))
+	}
+	return nil
+}
+
+// timespec Stores d in the SQE, which keeps it alive until the SQE's last completion,
+// and gets a pointer to it for the kernel. A negative d is stored as 0.
+func (s *SQE) timespec(d time.Duration) *C.struct___kernel_timespec {
+	if d < 0 {
+		d = 0
+	}
+
+	s.wrapped = true
+	return C.set_timespec(s.sqe, C.longlong(d))
 }
 
-// SetData Set the data for a submission queue event.
-func (s *SQE) SetData(data []byte) {
-	C.io_uring_sqe_set_data(s.sqe.sqe, unsafe.Pointer(&data[0]))
+// pin Pins bufs until the SQE is handed back to the ring or discarded, so their addresses can be
+// stored in C memory, as the cgo pointer rules require. Registered buffers are C memory, pinning them does nothing.
+func (s *SQE) pin(bufs ...[]byte) {
+	for _, buf := range bufs {
+		if len(buf) > 0 {
+			s.pinner.Pin(&buf[0])
+			s.pinned = true
+		}
+	}
+}
+
+// unpin Releases the buffers pinned by `pin`.
+func (s *SQE) unpin() {
+	s.pinner.Unpin()
+	s.pinned = false
+}
+
+// bufferPointer Gets a pointer to the start of data, or nil if data is empty.
+func bufferPointer(data []byte) unsafe.Pointer {
+	if len(data) == 0 {
+		return nil
+	}
+
+	return unsafe.Pointer(&data[0])
 }
@@ -0,0 +1,41 @@
+package goliburing
+
+// WaitPolicy Controls how the file readers and writers wait for a completion.
+// The zero value blocks in the kernel straight away, so an idle waiter uses no CPU.
+type WaitPolicy struct {
+	// Spin The maximum number of times the completion queue is polled
+	// before blocking in the kernel. Spinning lowers the latency of
+	// completions that arrive quickly, at the cost of CPU time.
+	Spin uint32
+}
+
+// DefaultWaitPolicy Blocks in the kernel without spinning.
+var DefaultWaitPolicy = WaitPolicy{}
+
+// reap Waits for a completion of an SQE that match accepts on ring, following the policy.
+// The other completions are left in the ring's backlog for their own reapers.
+func (p WaitPolicy) reap(ring *Ring, match func(sqe *SQE) bool) (*CQE, error) {
+	for i := uint32(0); i < p.Spin; i++ {
+		if cqe, err := ring.reap(match, 0); err == nil {
+			return cqe, nil
+		}
+	}
+
+	return ring.reap(match, -1)
+}
+
+// waitFor Waits for the completion with the given user data on ring, following the policy,
+// and gets its raw result. The SQE must be claimed, see `SQE.claimed`, so that `Ring.WaitCQE`
+// leaves its completion alone, and other completions are left for their own reapers.
+func (p WaitPolicy) waitFor(ring *Ring, userData uint64) (int32, error) {
+	cqe, err := p.reap(ring, func(sqe *SQE) bool {
+		return sqe != nil && sqe.token == userData
+	})
+	if err != nil {
+		return 0, err
+	}
+	res := cqe.Res()
+	cqe.Seen()
+
+	return res, nil
+}